@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	cfg "github.com/conductorone/baton-hubspot/pkg/config"
 	"github.com/conductorone/baton-hubspot/pkg/connector"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
 	"github.com/conductorone/baton-sdk/pkg/config"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/types"
@@ -42,7 +44,27 @@ func main() {
 func getConnector(ctx context.Context, hsc *cfg.Hubspot) (types.ConnectorServer, error) {
 	l := ctxzap.Extract(ctx)
 
-	hubspotConnector, err := connector.New(ctx, hsc.Token, hsc.UserStatus)
+	var oauth *hubspot.OAuthCredentials
+	if hsc.OauthRefreshToken != "" {
+		oauth = &hubspot.OAuthCredentials{
+			ClientId:     hsc.OauthClientId,
+			ClientSecret: hsc.OauthClientSecret,
+			RefreshToken: hsc.OauthRefreshToken,
+		}
+	}
+
+	hubspotConnector, err := connector.New(ctx, connector.Config{
+		AccessToken:      hsc.Token,
+		OAuth:            oauth,
+		PortalTokens:     hsc.PortalTokens,
+		BaseURL:          hsc.BaseUrl,
+		UserStatus:       hsc.UserStatus,
+		LastLogin:        hsc.LastLogin,
+		ExpandChildTeams: hsc.ExpandChildTeams,
+		SyncOwners:       hsc.Owners,
+		SyncSandboxes:    hsc.Sandboxes,
+		RetryBudget:      time.Duration(hsc.RetryBudget) * time.Second,
+	})
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
@@ -5,7 +5,17 @@ import "reflect"
 
 type Hubspot struct {
 	Token string `mapstructure:"token"`
+	OauthClientId string `mapstructure:"oauth-client-id"`
+	OauthClientSecret string `mapstructure:"oauth-client-secret"`
+	OauthRefreshToken string `mapstructure:"oauth-refresh-token"`
+	PortalTokens []string `mapstructure:"portal-tokens"`
+	BaseUrl string `mapstructure:"base-url"`
 	UserStatus bool `mapstructure:"user-status"`
+	LastLogin bool `mapstructure:"last-login"`
+	ExpandChildTeams bool `mapstructure:"expand-child-teams"`
+	Owners bool `mapstructure:"owners"`
+	Sandboxes bool `mapstructure:"sandboxes"`
+	RetryBudget int `mapstructure:"retry-budget"`
 }
 
 func (c* Hubspot) findFieldByTag(tagValue string) (any, bool) {
@@ -9,20 +9,96 @@ var (
 		"token",
 		field.WithDisplayName("API client secret"),
 		field.WithDescription("The HubSpot personal access token used to connect to the HubSpot API. ($BATON_TOKEN)"),
-		field.WithRequired(true),
 		field.WithIsSecret(true),
 	)
+	OAuthClientIdField = field.StringField(
+		"oauth-client-id",
+		field.WithDisplayName("OAuth client ID"),
+		field.WithDescription("The client ID of the HubSpot OAuth app. ($BATON_OAUTH_CLIENT_ID)"),
+	)
+	OAuthClientSecretField = field.StringField(
+		"oauth-client-secret",
+		field.WithDisplayName("OAuth client secret"),
+		field.WithDescription("The client secret of the HubSpot OAuth app. ($BATON_OAUTH_CLIENT_SECRET)"),
+		field.WithIsSecret(true),
+	)
+	OAuthRefreshTokenField = field.StringField(
+		"oauth-refresh-token",
+		field.WithDisplayName("OAuth refresh token"),
+		field.WithDescription("The refresh token of the HubSpot OAuth app installation used to obtain access tokens. ($BATON_OAUTH_REFRESH_TOKEN)"),
+		field.WithIsSecret(true),
+	)
+	PortalTokensField = field.StringSliceField(
+		"portal-tokens",
+		field.WithDisplayName("Additional portal tokens"),
+		field.WithDescription("Access tokens of additional HubSpot portals synced along the primary one, each portal is synced as its own account. ($BATON_PORTAL_TOKENS)"),
+		field.WithIsSecret(true),
+	)
+	BaseURLField = field.StringField(
+		"base-url",
+		field.WithDisplayName("API base URL"),
+		field.WithDescription("The base URL of the HubSpot API, such as 'https://api-eu1.hubapi.com' for portals hosted in the EU data center or the address of an egress proxy. ($BATON_BASE_URL)"),
+		field.WithDefaultValue("https://api.hubapi.com"),
+	)
 	UserStatusField = field.BoolField(
 		"user-status",
 		field.WithDisplayName("User status"),
 		field.WithDescription("Enables user status syncing. WARNING: Additional token scope needed: 'crm.objects.users.read'. ($BATON_USER_STATUS)"),
 		field.WithDefaultValue(false),
 	)
+	LastLoginField = field.BoolField(
+		"last-login",
+		field.WithDisplayName("Last login"),
+		field.WithDescription("Enables syncing of user last login from the login activity. Additional token scope needed: 'account-info.security.read'. ($BATON_LAST_LOGIN)"),
+		field.WithDefaultValue(true),
+	)
+	ExpandChildTeamsField = field.BoolField(
+		"expand-child-teams",
+		field.WithDisplayName("Expand child teams"),
+		field.WithDescription("Grants membership of parent teams to the members of their child teams. ($BATON_EXPAND_CHILD_TEAMS)"),
+		field.WithDefaultValue(false),
+	)
+	OwnersField = field.BoolField(
+		"owners",
+		field.WithDisplayName("CRM owners"),
+		field.WithDescription("Enables syncing of CRM owners, including archived ones. Additional token scope needed: 'crm.objects.owners.read'. ($BATON_OWNERS)"),
+		field.WithDefaultValue(false),
+	)
+	SandboxesField = field.BoolField(
+		"sandboxes",
+		field.WithDisplayName("Sandboxes"),
+		field.WithDescription("Enables discovery of the standard and development sandboxes of Enterprise accounts, synced under their production account. Users and roles are synced for sandboxes with a portal token. ($BATON_SANDBOXES)"),
+		field.WithDefaultValue(false),
+	)
+	RetryBudgetField = field.IntField(
+		"retry-budget",
+		field.WithDisplayName("Retry budget"),
+		field.WithDescription("Maximum number of seconds spent retrying a rate limited or failed HubSpot API request, 0 disables retries. ($BATON_RETRY_BUDGET)"),
+		field.WithDefaultValue(120),
+	)
 )
 
 //go:generate go run ./gen
 var Config = field.NewConfiguration(
-	[]field.SchemaField{TokenField, UserStatusField},
+	[]field.SchemaField{
+		TokenField,
+		OAuthClientIdField,
+		OAuthClientSecretField,
+		OAuthRefreshTokenField,
+		PortalTokensField,
+		BaseURLField,
+		UserStatusField,
+		LastLoginField,
+		ExpandChildTeamsField,
+		OwnersField,
+		SandboxesField,
+		RetryBudgetField,
+	},
+	field.WithConstraints(
+		field.FieldsAtLeastOneUsed(TokenField, OAuthRefreshTokenField),
+		field.FieldsMutuallyExclusive(TokenField, OAuthRefreshTokenField),
+		field.FieldsRequiredTogether(OAuthClientIdField, OAuthClientSecretField, OAuthRefreshTokenField),
+	),
 	field.WithConnectorDisplayName("HubSpot"),
 	field.WithHelpUrl("/docs/baton/hubspot"),
 	field.WithIconUrl("/static/app-icons/hubspot.svg"),
@@ -11,31 +11,52 @@ import (
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/proto"
 )
 
 const accountMembership = "member"
 
 type accountResourceType struct {
-	resourceType *v2.ResourceType
-	client       *hubspot.Client
+	resourceType  *v2.ResourceType
+	portals       *portalSet
+	syncOwners    bool
+	syncSandboxes bool
 }
 
 func (acc *accountResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return acc.resourceType
 }
 
+// childResourceTypes returns the resource types synced under the account of a portal,
+// sandboxes are only linked to production accounts.
+func (acc *accountResourceType) childResourceTypes(production bool) []proto.Message {
+	childResourceTypes := []proto.Message{
+		&v2.ChildResourceType{ResourceTypeId: resourceTypeUser.Id},
+		&v2.ChildResourceType{ResourceTypeId: resourceTypeTeam.Id},
+		&v2.ChildResourceType{ResourceTypeId: resourceTypeRole.Id},
+		&v2.ChildResourceType{ResourceTypeId: resourceTypeBusinessUnit.Id},
+		&v2.ChildResourceType{ResourceTypeId: resourceTypeApp.Id},
+	}
+
+	if acc.syncOwners {
+		childResourceTypes = append(childResourceTypes, &v2.ChildResourceType{ResourceTypeId: resourceTypeOwner.Id})
+	}
+
+	if acc.syncSandboxes && production {
+		childResourceTypes = append(childResourceTypes, &v2.ChildResourceType{ResourceTypeId: resourceTypeAccount.Id})
+	}
+
+	return childResourceTypes
+}
+
 // Create a new connector resource for an HubSpot account.
-func accountResource(_ context.Context, account *hubspot.Account, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+func accountResource(_ context.Context, account *hubspot.Account, parentResourceID *v2.ResourceId, childResourceTypes []proto.Message) (*v2.Resource, error) {
 	resource, err := rs.NewResource(
 		fmt.Sprint(account.Id),
 		resourceTypeAccount,
 		account.Id,
 		rs.WithParentResourceID(parentResourceID),
-		rs.WithAnnotation(
-			&v2.ChildResourceType{ResourceTypeId: resourceTypeUser.Id},
-			&v2.ChildResourceType{ResourceTypeId: resourceTypeTeam.Id},
-			&v2.ChildResourceType{ResourceTypeId: resourceTypeRole.Id},
-		),
+		rs.WithAnnotation(childResourceTypes...),
 	)
 
 	if err != nil {
@@ -45,21 +66,137 @@ func accountResource(_ context.Context, account *hubspot.Account, parentResource
 	return resource, nil
 }
 
-func (acc *accountResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	account, annotations, err := acc.client.GetAccount(ctx)
+// Create a new connector resource for a sandbox of an HubSpot account. The users and roles of the
+// sandbox are synced under it only when the connector has credentials for the sandbox.
+func sandboxResource(_ context.Context, sandbox *hubspot.Sandbox, parentResourceID *v2.ResourceId, childResourceTypes []proto.Message) (*v2.Resource, error) {
+	displayName := sandbox.Name
+	if displayName == "" {
+		displayName = fmt.Sprint(sandbox.Id)
+	}
+
+	resource, err := rs.NewResource(
+		displayName,
+		resourceTypeAccount,
+		sandbox.Id,
+		rs.WithParentResourceID(parentResourceID),
+		rs.WithDescription(fmt.Sprintf("%s sandbox of account %d", titleCase(sandbox.Type), sandbox.ParentId)),
+		rs.WithAnnotation(childResourceTypes...),
+	)
+
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list account: %w", err)
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns one account for every portal the connector has credentials for. Sandboxes are
+// listed under the account of their production portal instead.
+func (acc *accountResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentId != nil {
+		return acc.listSandboxes(ctx, parentId)
+	}
+
+	var (
+		accounts []hubspot.Account
+		annos    annotations.Annotations
+	)
+	for _, p := range acc.portals.portals {
+		// the account is the root of every sync, start each one with fresh users, business units and sandboxes
+		if token.Token == "" {
+			p.users.Invalidate()
+			p.businessUnits.Invalidate()
+			p.sandboxes.Invalidate()
+		}
+
+		account, annotations, err := p.client.GetAccount(ctx)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list account: %w", err)
+		}
+		annos = annotations
+		accounts = append(accounts, account)
+	}
+
+	sandboxIds := make(map[int]bool)
+	if acc.syncSandboxes {
+		for i, p := range acc.portals.portals {
+			if !accounts[i].IsProduction() {
+				continue
+			}
+
+			sandboxes, _, err := p.sandboxes.Sandboxes(ctx)
+			if err != nil {
+				if skipUnavailable(ctx, err, "sandboxes", fmt.Sprint(accounts[i].Id)) {
+					continue
+				}
+
+				return nil, "", nil, err
+			}
+			for _, sandbox := range sandboxes {
+				sandboxIds[sandbox.Id] = true
+			}
+		}
 	}
 
 	var rv []*v2.Resource
-	accountCopy := account
-	ar, err := accountResource(ctx, &accountCopy, parentId)
+	for _, account := range accounts {
+		if sandboxIds[account.Id] {
+			continue
+		}
+
+		accountCopy := account
+		ar, err := accountResource(ctx, &accountCopy, parentId, acc.childResourceTypes(account.IsProduction()))
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, ar)
+	}
+
+	return rv, "", annos, nil
+}
+
+// listSandboxes returns the sandboxes linked to the production account.
+func (acc *accountResourceType) listSandboxes(ctx context.Context, parentId *v2.ResourceId) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentId.ResourceType != resourceTypeAccount.Id {
+		return nil, "", nil, nil
+	}
+
+	p, err := acc.portals.forAccount(ctx, parentId.Resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
-	rv = append(rv, ar)
 
-	return rv, "", annotations, nil
+	sandboxes, annos, err := p.sandboxes.Sandboxes(ctx)
+	if err != nil {
+		// sandboxes require an Enterprise account and the sandboxes.read scope, the sync goes on without them
+		if skipUnavailable(ctx, err, "sandboxes", parentId.Resource) {
+			return nil, "", annos, nil
+		}
+
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, sandbox := range sandboxes {
+		sandboxPortal, err := acc.portals.findAccount(ctx, fmt.Sprint(sandbox.Id))
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		var childResourceTypes []proto.Message
+		if sandboxPortal != nil {
+			childResourceTypes = acc.childResourceTypes(false)
+		}
+
+		sandboxCopy := sandbox
+		sr, err := sandboxResource(ctx, &sandboxCopy, parentId, childResourceTypes)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, sr)
+	}
+
+	return rv, "", annos, nil
 }
 
 func (acc *accountResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
@@ -82,28 +219,36 @@ func (acc *accountResourceType) Entitlements(ctx context.Context, resource *v2.R
 }
 
 func (acc *accountResourceType) Grants(ctx context.Context, resource *v2.Resource, token *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	// parse the roleIDs from the users
 	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	users, nextToken, annotations, err := acc.client.GetUsers(
-		ctx,
-		hubspot.GetUsersVars{Limit: ResourcesPageSize, After: bag.PageToken()},
-	)
+	p, err := acc.portals.findAccount(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	// the users of sandboxes without credentials are unknown
+	if p == nil {
+		return nil, "", nil, nil
+	}
+
+	users, annotations, err := p.users.Users(ctx)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	pageToken, err := bag.NextToken(nextToken)
+	start, end, pageToken, err := offsetPage(bag, len(users))
 	if err != nil {
 		return nil, "", nil, err
 	}
 
 	var rv []*v2.Grant
-	for _, user := range users {
-		userResourceId := getUserResourceId(user.Id)
+	for _, user := range users[start:end] {
+		userResourceId, err := acc.portals.userResourceId(ctx, p, user.Id)
+		if err != nil {
+			return nil, "", nil, err
+		}
 		rv = append(
 			rv,
 			grant.NewGrant(
@@ -117,9 +262,11 @@ func (acc *accountResourceType) Grants(ctx context.Context, resource *v2.Resourc
 	return rv, pageToken, annotations, nil
 }
 
-func accountBuilder(client *hubspot.Client) *accountResourceType {
+func accountBuilder(portals *portalSet, syncOwners bool, syncSandboxes bool) *accountResourceType {
 	return &accountResourceType{
-		resourceType: resourceTypeAccount,
-		client:       client,
+		resourceType:  resourceTypeAccount,
+		portals:       portals,
+		syncOwners:    syncOwners,
+		syncSandboxes: syncSandboxes,
 	}
 }
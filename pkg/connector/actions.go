@@ -0,0 +1,222 @@
+package connector
+
+import (
+	"context"
+	"sync"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/actions"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// actionManager reports the progress of running actions through GetActionStatus,
+// the SDK action manager only returns a result once the handler is done.
+type actionManager struct {
+	*actions.ActionManager
+	mtx      sync.Mutex
+	progress map[string]*actionProgress
+}
+
+func newActionManager(ctx context.Context) *actionManager {
+	return &actionManager{
+		ActionManager: actions.NewActionManager(ctx),
+		progress:      make(map[string]*actionProgress),
+	}
+}
+
+func (m *actionManager) InvokeAction(
+	ctx context.Context,
+	name string,
+	args *structpb.Struct,
+) (string, v2.BatonActionStatus, *structpb.Struct, annotations.Annotations, error) {
+	progress := &actionProgress{}
+	id, actionStatus, rv, annos, err := m.ActionManager.InvokeAction(withActionProgress(ctx, progress), name, args)
+	if id != "" && !actionDone(actionStatus) {
+		m.mtx.Lock()
+		m.progress[id] = progress
+		m.mtx.Unlock()
+	}
+
+	return id, actionStatus, rv, annos, err
+}
+
+func (m *actionManager) GetActionStatus(
+	ctx context.Context,
+	actionId string,
+) (v2.BatonActionStatus, string, *structpb.Struct, annotations.Annotations, error) {
+	actionStatus, name, rv, annos, err := m.ActionManager.GetActionStatus(ctx, actionId)
+	if err != nil {
+		return actionStatus, name, rv, annos, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	progress, ok := m.progress[actionId]
+	if !ok {
+		return actionStatus, name, rv, annos, nil
+	}
+
+	if actionDone(actionStatus) {
+		delete(m.progress, actionId)
+		return actionStatus, name, rv, annos, nil
+	}
+
+	return actionStatus, name, progress.snapshot(ctx), annos, nil
+}
+
+func actionDone(actionStatus v2.BatonActionStatus) bool {
+	return actionStatus == v2.BatonActionStatus_BATON_ACTION_STATUS_COMPLETE ||
+		actionStatus == v2.BatonActionStatus_BATON_ACTION_STATUS_FAILED
+}
+
+// actionProgress holds the intermediate results of a running action.
+type actionProgress struct {
+	mtx    sync.Mutex
+	values map[string]interface{}
+}
+
+type actionProgressKey struct{}
+
+func withActionProgress(ctx context.Context, progress *actionProgress) context.Context {
+	return context.WithValue(ctx, actionProgressKey{}, progress)
+}
+
+// actionProgressFromContext returns the progress of the running action,
+// progress is discarded when the handler runs outside of the action manager.
+func actionProgressFromContext(ctx context.Context) *actionProgress {
+	if progress, ok := ctx.Value(actionProgressKey{}).(*actionProgress); ok {
+		return progress
+	}
+
+	return &actionProgress{}
+}
+
+func (p *actionProgress) set(key string, value interface{}) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.values == nil {
+		p.values = make(map[string]interface{})
+	}
+	p.values[key] = value
+}
+
+func (p *actionProgress) snapshot(ctx context.Context) *structpb.Struct {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	rv, err := structpb.NewStruct(p.values)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn("hubspot-connector: failed to report action progress", zap.Error(err))
+		return nil
+	}
+
+	return rv
+}
+
+// customAction is an action together with its schema.
+type customAction struct {
+	schema  *v2.BatonActionSchema
+	handler actions.ActionHandler
+}
+
+func (hs *HubSpot) customActions() []customAction {
+	return []customAction{
+		{schema: transferOwnershipSchema(), handler: hs.transferOwnership},
+		{schema: deactivateUserSchema(), handler: hs.deactivateUser},
+		{schema: reactivateUserSchema(), handler: hs.reactivateUser},
+		{schema: setPrimaryTeamSchema(), handler: hs.setPrimaryTeam},
+		{schema: makeSuperAdminSchema(), handler: hs.makeSuperAdmin},
+		{schema: removeSuperAdminSchema(), handler: hs.removeSuperAdmin},
+	}
+}
+
+// RegisterActionManager returns the custom actions supported by the connector.
+func (hs *HubSpot) RegisterActionManager(ctx context.Context) (connectorbuilder.CustomActionManager, error) {
+	manager := newActionManager(ctx)
+
+	for _, action := range hs.customActions() {
+		err := manager.RegisterAction(ctx, action.schema.Name, action.schema, action.handler)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return manager, nil
+}
+
+func stringArgField(name, displayName, description string, required bool) *config.Field {
+	return &config.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Description: description,
+		IsRequired:  required,
+		Field: &config.Field_StringField{
+			StringField: &config.StringField{},
+		},
+	}
+}
+
+func intReturnField(name, displayName string) *config.Field {
+	return &config.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Field: &config.Field_IntField{
+			IntField: &config.IntField{},
+		},
+	}
+}
+
+func boolReturnField(name, displayName string) *config.Field {
+	return &config.Field{
+		Name:        name,
+		DisplayName: displayName,
+		Field: &config.Field_BoolField{
+			BoolField: &config.BoolField{},
+		},
+	}
+}
+
+// stringArg returns the value of a string argument, an empty value is an error for required arguments.
+func stringArg(args *structpb.Struct, name string, required bool) (string, error) {
+	var rv string
+	if value, ok := args.GetFields()[name]; ok {
+		str, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return "", status.Errorf(codes.InvalidArgument, "hubspot-connector: argument %s must be a string", name)
+		}
+		rv = str.StringValue
+	}
+
+	if rv == "" && required {
+		return "", status.Errorf(codes.InvalidArgument, "hubspot-connector: argument %s is required", name)
+	}
+
+	return rv, nil
+}
+
+// stringSliceArg returns the value of a list of strings argument.
+func stringSliceArg(args *structpb.Struct, name string) ([]string, error) {
+	value, ok := args.GetFields()[name]
+	if !ok {
+		return nil, nil
+	}
+
+	var rv []string
+	for _, item := range value.GetListValue().GetValues() {
+		str, ok := item.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "hubspot-connector: argument %s must be a list of strings", name)
+		}
+		rv = append(rv, str.StringValue)
+	}
+
+	return rv, nil
+}
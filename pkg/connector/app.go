@@ -0,0 +1,160 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+const (
+	appTypePrivate   = "private"
+	appTypeConnected = "connected"
+)
+
+type appResourceType struct {
+	resourceType *v2.ResourceType
+	portals      *portalSet
+}
+
+func (a *appResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return a.resourceType
+}
+
+// Create a new connector resource for the HubSpot private app or OAuth app described by the token info.
+// Apps are synced as service accounts, the scopes granted to an app are its entitlements.
+func appResource(tokenInfo *hubspot.TokenInfo, appResourceId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	appType, displayName := appTypeConnected, fmt.Sprintf("OAuth app %d", tokenInfo.AppId)
+	if tokenInfo.PrivateApp {
+		appType, displayName = appTypePrivate, fmt.Sprintf("Private app %d", tokenInfo.AppId)
+	}
+
+	profile := map[string]interface{}{
+		"app_id":   strconv.Itoa(tokenInfo.AppId),
+		"app_type": appType,
+	}
+
+	if len(tokenInfo.Scopes) > 0 {
+		profile["app_scopes"] = strings.Join(tokenInfo.Scopes, ",")
+	}
+
+	if tokenInfo.UserId != 0 {
+		profile["user_id"] = strconv.Itoa(tokenInfo.UserId)
+	}
+
+	userTraitOptions := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
+		rs.WithAccountType(v2.UserTrait_ACCOUNT_TYPE_SERVICE),
+	}
+
+	resource, err := rs.NewUserResource(
+		displayName,
+		resourceTypeApp,
+		appResourceId,
+		userTraitOptions,
+		rs.WithParentResourceID(parentResourceID),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns the app the connector is authenticated as in the portal. HubSpot has no API listing
+// the private apps and OAuth apps of an account, so the app is described by the introspection of
+// the token configured for the portal.
+func (a *appResourceType) List(ctx context.Context, parentId *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentId == nil {
+		return nil, "", nil, nil
+	}
+
+	p, err := a.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	tokenInfo, annos, err := p.client.GetTokenInfo(ctx)
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("hubspot-connector: failed to get token info: %w", err)
+	}
+
+	// the introspection did not tell which app the token was issued for
+	if tokenInfo.AppId == 0 {
+		return nil, "", annos, nil
+	}
+
+	// private and OAuth apps are numbered separately
+	appType := appTypeConnected
+	if tokenInfo.PrivateApp {
+		appType = appTypePrivate
+	}
+	appResourceId, err := a.portals.qualifiedId(ctx, p, appType+":"+strconv.Itoa(tokenInfo.AppId))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	ar, err := appResource(&tokenInfo, appResourceId, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.Resource{ar}, "", annos, nil
+}
+
+// appScopes returns the scopes granted to the app, as stored on its profile.
+func appScopes(resource *v2.Resource) ([]string, error) {
+	appTrait, err := rs.GetUserTrait(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, ok := rs.GetProfileStringValue(appTrait.Profile, "app_scopes")
+	if !ok || scopes == "" {
+		return nil, nil
+	}
+
+	return strings.Split(scopes, ","), nil
+}
+
+func (a *appResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	scopes, err := appScopes(resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Entitlement
+	for _, scope := range scopes {
+		permissionOptions := []ent.EntitlementOption{
+			ent.WithGrantableTo(resourceTypeApp),
+			ent.WithDisplayName(fmt.Sprintf("%s %s", resource.DisplayName, scope)),
+			ent.WithDescription(fmt.Sprintf("Scope %s granted to %s in HubSpot", scope, resource.DisplayName)),
+		}
+
+		rv = append(rv, ent.NewPermissionEntitlement(resource, scope, permissionOptions...))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grants returns no grants, the scopes are granted to the app by its installation rather than
+// held by principals, they are only described by the entitlements and the profile of the app.
+func (a *appResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func appBuilder(portals *portalSet) *appResourceType {
+	return &appResourceType{
+		resourceType: resourceTypeApp,
+		portals:      portals,
+	}
+}
@@ -0,0 +1,111 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAppsOfEveryPortal(t *testing.T) {
+	primary, other, portals := twoPortals(t)
+	builder := appBuilder(portals)
+
+	// both portals use the same private app
+	primary.SetApp(42, 7)
+	primary.SetScopes("crm.objects.contacts.read", "settings.users.read")
+	other.SetApp(42, 8)
+	other.SetScopes("settings.users.read")
+
+	apps := listAll(t, builder, accountId(hubspottest.DefaultPortalId))
+	if len(apps) != 1 || apps[0].Id.Resource != "private:42" {
+		t.Fatalf("expected the private app of the primary portal, got %v", apps)
+	}
+
+	appTrait, err := rs.GetUserTrait(apps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appTrait.AccountType != v2.UserTrait_ACCOUNT_TYPE_SERVICE {
+		t.Fatalf("expected a service account, got %v", appTrait.AccountType)
+	}
+	if userId, _ := rs.GetProfileStringValue(appTrait.Profile, "user_id"); userId != "7" {
+		t.Fatalf("expected the user of the token on the profile, got %q", userId)
+	}
+
+	entitlements, _, _, err := builder.Entitlements(context.Background(), apps[0], &pagination.Token{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entitlements) != 2 || entitlements[0].Slug != "crm.objects.contacts.read" || entitlements[1].Slug != "settings.users.read" {
+		t.Fatalf("expected the scopes of the app as entitlements, got %v", entitlements)
+	}
+
+	// the app is not granted its own scopes
+	if got := grantsOf(t, builder, apps[0]); len(got) != 0 {
+		t.Fatalf("expected no grants, got %v", got)
+	}
+
+	apps = listAll(t, builder, accountId(67890))
+	if len(apps) != 1 || apps[0].Id.Resource != "private:42:67890" {
+		t.Fatalf("expected the private app of the other portal, got %v", apps)
+	}
+}
+
+func TestAppsOfOAuthCredentials(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetOAuthApp(hubspottest.OAuthApp{ClientId: "client", ClientSecret: "secret", RefreshToken: "refresh"})
+	s.SetApp(42, 7)
+
+	hs, err := New(context.Background(), Config{
+		OAuth:   &hubspot.OAuthCredentials{ClientId: "client", ClientSecret: "secret", RefreshToken: "refresh"},
+		BaseURL: s.BaseURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apps := listAll(t, appBuilder(hs.portals), accountId(hubspottest.DefaultPortalId))
+	if len(apps) != 1 || apps[0].Id.Resource != "connected:42" {
+		t.Fatalf("expected the OAuth app, got %v", apps)
+	}
+}
+
+func TestAppsWithoutApp(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	if apps := listAll(t, appBuilder(newTestConnector(t, s).portals), accountId(hubspottest.DefaultPortalId)); len(apps) != 0 {
+		t.Fatalf("expected no app when the token info has none, got %v", apps)
+	}
+}
+
+func TestAppsReturnTokenInfoErrors(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetApp(42, 7)
+	s.InjectFailure(hubspottest.Failure{
+		Path:       "/" + hubspot.PrivateAppTokenInfoURL,
+		StatusCode: http.StatusForbidden,
+		Category:   "MISSING_SCOPES",
+	})
+
+	_, _, _, err := appBuilder(newTestConnector(t, s).portals).List(
+		context.Background(),
+		accountId(hubspottest.DefaultPortalId),
+		&pagination.Token{},
+	)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+}
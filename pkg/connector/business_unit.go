@@ -0,0 +1,231 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const businessUnitMembership = "member"
+
+type businessUnitResourceType struct {
+	resourceType *v2.ResourceType
+	portals      *portalSet
+}
+
+func (b *businessUnitResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return b.resourceType
+}
+
+// Create a new connector resource for an HubSpot business unit, resourceId being its ID qualified for the portal.
+func businessUnitResource(businessUnit *hubspot.BusinessUnit, resourceId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"business_unit_id":   businessUnit.Id,
+		"business_unit_name": businessUnit.Name,
+	}
+
+	resource, err := rs.NewGroupResource(
+		businessUnit.Name,
+		resourceTypeBusinessUnit,
+		resourceId,
+		[]rs.GroupTraitOption{rs.WithGroupProfile(profile)},
+		rs.WithParentResourceID(parentResourceID),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (b *businessUnitResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentId == nil {
+		return nil, "", nil, nil
+	}
+
+	p, err := b.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeBusinessUnit.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	businessUnits, annotations, err := p.businessUnits.BusinessUnits(ctx)
+	if err != nil {
+		// business units require the Business Units add-on, the sync goes on without them
+		if skipUnavailable(ctx, err, "business units", parentId.Resource) {
+			return nil, "", annotations, nil
+		}
+
+		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list business units: %w", err)
+	}
+
+	start, end, pageToken, err := offsetPage(bag, len(businessUnits))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, businessUnit := range businessUnits[start:end] {
+		businessUnitCopy := businessUnit
+
+		resourceId, err := b.portals.qualifiedId(ctx, p, businessUnit.Id)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		br, err := businessUnitResource(&businessUnitCopy, resourceId, parentId)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, br)
+	}
+
+	return rv, pageToken, annotations, nil
+}
+
+func (b *businessUnitResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	assignmentOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithDisplayName(fmt.Sprintf("%s Business Unit %s", resource.DisplayName, businessUnitMembership)),
+		ent.WithDescription(fmt.Sprintf("Access to %s business unit in HubSpot", resource.DisplayName)),
+	}
+
+	// create membership entitlement
+	rv = append(rv, ent.NewAssignmentEntitlement(
+		resource,
+		businessUnitMembership,
+		assignmentOptions...,
+	))
+
+	return rv, "", nil, nil
+}
+
+func (b *businessUnitResourceType) Grants(ctx context.Context, resource *v2.Resource, token *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	p, err := b.portals.forResource(ctx, resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	businessUnitId, _ := parseQualifiedId(resource.Id.Resource)
+	userIds, annotations, err := p.businessUnits.Members(ctx, businessUnitId)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list users of business unit %s: %w", resource.Id.Resource, err)
+	}
+
+	start, end, pageToken, err := offsetPage(bag, len(userIds))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, userId := range userIds[start:end] {
+		userResourceId, err := b.portals.userResourceId(ctx, p, userId)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		rv = append(rv, grant.NewGrant(
+			resource,
+			businessUnitMembership,
+			userResourceId,
+		))
+	}
+
+	return rv, pageToken, annotations, nil
+}
+
+func (b *businessUnitResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"hubspot-connector: only users can be granted business unit membership",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("hubspot-connector: only users can be granted business unit membership")
+	}
+
+	p, err := b.portals.forResource(ctx, entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := b.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	businessUnitId, _ := parseQualifiedId(entitlement.Resource.Id.Resource)
+	annos, err := p.client.AddUserToBusinessUnit(ctx, businessUnitId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to add user to business unit: %w", err)
+	}
+	p.businessUnits.Invalidate()
+
+	return annos, nil
+}
+
+func (b *businessUnitResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	principal := grant.Principal
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		l.Warn(
+			"hubspot-connector: only users can have business unit membership revoked",
+			zap.String("principal_id", principal.Id.Resource),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("hubspot-connector: only users can have business unit membership revoked")
+	}
+
+	p, err := b.portals.forResource(ctx, grant.Entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := b.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	businessUnitId, _ := parseQualifiedId(grant.Entitlement.Resource.Id.Resource)
+	annos, err := p.client.RemoveUserFromBusinessUnit(ctx, businessUnitId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to remove user from business unit: %w", err)
+	}
+	p.businessUnits.Invalidate()
+
+	return annos, nil
+}
+
+func businessUnitBuilder(portals *portalSet) *businessUnitResourceType {
+	return &businessUnitResourceType{
+		resourceType: resourceTypeBusinessUnit,
+		portals:      portals,
+	}
+}
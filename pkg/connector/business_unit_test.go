@@ -0,0 +1,108 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+func TestBusinessUnitsListAndGrants(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com"})
+	john := s.AddUser(hubspot.User{Email: "john@example.com"})
+	emea := s.AddBusinessUnit(hubspot.BusinessUnit{Name: "EMEA"}, jane.Id, john.Id)
+	s.AddBusinessUnit(hubspot.BusinessUnit{Name: "APAC"})
+
+	builder := businessUnitBuilder(newTestConnector(t, s).portals)
+
+	// units are discovered from the units of each user, those without members are not listed
+	businessUnits := listAll(t, builder, accountId(hubspottest.DefaultPortalId))
+	if len(businessUnits) != 1 {
+		t.Fatalf("expected 1 business unit, got %d", len(businessUnits))
+	}
+
+	want := []string{
+		"business_unit:" + emea.Id + ":member/" + jane.Id,
+		"business_unit:" + emea.Id + ":member/" + john.Id,
+	}
+	if got := grantsOf(t, builder, businessUnits[0]); !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// one request per user, the members are not listed again for the grants
+	lookups := 0
+	for _, request := range s.Requests() {
+		if strings.HasPrefix(request.Path, "/business-units/") {
+			lookups++
+		}
+	}
+	if lookups != 2 {
+		t.Fatalf("expected 2 lookups of business units, got %d", lookups)
+	}
+}
+
+func TestBusinessUnitsNotAvailable(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com"})
+	path := "/" + fmt.Sprintf(hubspot.UserBusinessUnitsURL, jane.Id)
+
+	p := newTestConnector(t, s).portals
+	builder := businessUnitBuilder(p)
+
+	s.InjectFailure(hubspottest.Failure{Path: path, StatusCode: http.StatusForbidden, Category: "MISSING_SCOPES"})
+	businessUnits, _, _, err := builder.List(ctx, accountId(hubspottest.DefaultPortalId), &pagination.Token{})
+	if err != nil || len(businessUnits) != 0 {
+		t.Fatalf("expected business units to be skipped without the add-on, got %v %v", businessUnits, err)
+	}
+
+	p.primary().businessUnits.Invalidate()
+	s.InjectFailure(hubspottest.Failure{Path: path, StatusCode: http.StatusInternalServerError})
+	if _, _, _, err := builder.List(ctx, accountId(hubspottest.DefaultPortalId), &pagination.Token{}); err == nil {
+		t.Fatal("expected other errors to fail the listing")
+	}
+}
+
+func TestBusinessUnitGrantAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com"})
+	emea := s.AddBusinessUnit(hubspot.BusinessUnit{Name: "EMEA"})
+
+	builder := businessUnitBuilder(newTestConnector(t, s).portals)
+	entitlement := &v2.Entitlement{
+		Resource: &v2.Resource{
+			Id:               &v2.ResourceId{ResourceType: resourceTypeBusinessUnit.Id, Resource: emea.Id},
+			ParentResourceId: accountId(hubspottest.DefaultPortalId),
+		},
+		Slug: businessUnitMembership,
+	}
+
+	if _, err := builder.Grant(ctx, userPrincipal(jane.Id), entitlement); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.BusinessUnitUsers(emea.Id); !slices.Equal(got, []string{jane.Id}) {
+		t.Fatalf("expected jane to be a member, got %v", got)
+	}
+
+	if _, err := builder.Revoke(ctx, &v2.Grant{Principal: userPrincipal(jane.Id), Entitlement: entitlement}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.BusinessUnitUsers(emea.Id); len(got) != 0 {
+		t.Fatalf("expected no member, got %v", got)
+	}
+}
@@ -0,0 +1,237 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+)
+
+const userSnapshotPageSize = 100
+
+// userSnapshot holds all users of the account for the duration of a single sync,
+// so that role, account and team grants are computed from one pass over the users.
+type userSnapshot struct {
+	client *hubspot.Client
+	mtx    sync.Mutex
+	loaded bool
+	users  []hubspot.User
+	byId   map[string]int
+}
+
+func newUserSnapshot(client *hubspot.Client) *userSnapshot {
+	return &userSnapshot{
+		client: client,
+	}
+}
+
+// load fetches all users once, subsequent calls reuse the loaded users until invalidated.
+func (s *userSnapshot) load(ctx context.Context) (annotations.Annotations, error) {
+	if s.loaded {
+		return nil, nil
+	}
+
+	var (
+		users []hubspot.User
+		annos annotations.Annotations
+		after string
+	)
+	for {
+		page, nextToken, pageAnnos, err := s.client.GetUsers(
+			ctx,
+			hubspot.GetUsersVars{Limit: userSnapshotPageSize, After: after},
+		)
+		if err != nil {
+			return pageAnnos, fmt.Errorf("hubspot-connector: failed to list users: %w", err)
+		}
+
+		users = append(users, page...)
+		annos = pageAnnos
+
+		if nextToken == "" {
+			break
+		}
+		after = nextToken
+	}
+
+	s.byId = make(map[string]int, len(users))
+	for i, user := range users {
+		s.byId[user.Id] = i
+	}
+	s.users = users
+	s.loaded = true
+
+	return annos, nil
+}
+
+// Users returns all users of the account.
+func (s *userSnapshot) Users(ctx context.Context) ([]hubspot.User, annotations.Annotations, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	annos, err := s.load(ctx)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return s.users, annos, nil
+}
+
+// Contains reports whether the user with provided id exists in the account.
+func (s *userSnapshot) Contains(ctx context.Context, userId string) (bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, err := s.load(ctx); err != nil {
+		return false, err
+	}
+
+	_, ok := s.byId[userId]
+	return ok, nil
+}
+
+// Invalidate drops the loaded users, the next read fetches them again.
+func (s *userSnapshot) Invalidate() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.loaded = false
+	s.users = nil
+	s.byId = nil
+}
+
+// sandboxSnapshot holds the sandboxes linked to the account for the duration of a single sync,
+// they are needed to list both the production accounts and the sandboxes under them.
+type sandboxSnapshot struct {
+	client    *hubspot.Client
+	mtx       sync.Mutex
+	loaded    bool
+	sandboxes []hubspot.Sandbox
+}
+
+func newSandboxSnapshot(client *hubspot.Client) *sandboxSnapshot {
+	return &sandboxSnapshot{
+		client: client,
+	}
+}
+
+// Sandboxes returns the sandboxes linked to the account, they are fetched once until invalidated.
+func (s *sandboxSnapshot) Sandboxes(ctx context.Context) ([]hubspot.Sandbox, annotations.Annotations, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.loaded {
+		return s.sandboxes, nil, nil
+	}
+
+	sandboxes, annos, err := s.client.GetSandboxes(ctx)
+	if err != nil {
+		return nil, annos, fmt.Errorf("hubspot-connector: failed to list sandboxes: %w", err)
+	}
+
+	s.sandboxes = sandboxes
+	s.loaded = true
+
+	return s.sandboxes, annos, nil
+}
+
+// Invalidate drops the loaded sandboxes, the next call fetches them again.
+func (s *sandboxSnapshot) Invalidate() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.loaded = false
+	s.sandboxes = nil
+}
+
+// businessUnitSnapshot holds the business units of the account and their members for the
+// duration of a single sync. HubSpot only lists the business units of a given user, so both
+// are collected from the users of the account, one request per user.
+type businessUnitSnapshot struct {
+	client        *hubspot.Client
+	users         *userSnapshot
+	mtx           sync.Mutex
+	loaded        bool
+	businessUnits []hubspot.BusinessUnit
+	members       map[string][]string
+}
+
+func newBusinessUnitSnapshot(client *hubspot.Client, users *userSnapshot) *businessUnitSnapshot {
+	return &businessUnitSnapshot{
+		client: client,
+		users:  users,
+	}
+}
+
+// load fetches the business units of every user once, subsequent calls reuse them until invalidated.
+func (s *businessUnitSnapshot) load(ctx context.Context) (annotations.Annotations, error) {
+	if s.loaded {
+		return nil, nil
+	}
+
+	users, annos, err := s.users.Users(ctx)
+	if err != nil {
+		return annos, err
+	}
+
+	var businessUnits []hubspot.BusinessUnit
+	members := make(map[string][]string)
+	for _, user := range users {
+		userBusinessUnits, userAnnos, err := s.client.GetUserBusinessUnits(ctx, user.Id)
+		if err != nil {
+			return userAnnos, fmt.Errorf("hubspot-connector: failed to list business units of user %s: %w", user.Id, err)
+		}
+		annos = userAnnos
+
+		for _, businessUnit := range userBusinessUnits {
+			if _, ok := members[businessUnit.Id]; !ok {
+				businessUnits = append(businessUnits, businessUnit)
+			}
+			members[businessUnit.Id] = append(members[businessUnit.Id], user.Id)
+		}
+	}
+
+	s.businessUnits = businessUnits
+	s.members = members
+	s.loaded = true
+
+	return annos, nil
+}
+
+// BusinessUnits returns the business units with at least one member in the account.
+func (s *businessUnitSnapshot) BusinessUnits(ctx context.Context) ([]hubspot.BusinessUnit, annotations.Annotations, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	annos, err := s.load(ctx)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return s.businessUnits, annos, nil
+}
+
+// Members returns the IDs of the users assigned to the business unit.
+func (s *businessUnitSnapshot) Members(ctx context.Context, businessUnitId string) ([]string, annotations.Annotations, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	annos, err := s.load(ctx)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return s.members[businessUnitId], annos, nil
+}
+
+// Invalidate drops the loaded business units, the next read fetches them again.
+func (s *businessUnitSnapshot) Invalidate() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.loaded = false
+	s.businessUnits = nil
+	s.members = nil
+}
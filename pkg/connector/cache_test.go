@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+)
+
+// benchmarkServer returns a server with the users spread across the roles and teams.
+func benchmarkServer(b *testing.B, users int, roles int, teams int) *hubspottest.Server {
+	b.Helper()
+
+	s := hubspottest.NewServer()
+	b.Cleanup(s.Close)
+	s.SetRateLimit(0, 0)
+
+	var roleIds, teamIds []string
+	for i := range roles {
+		roleIds = append(roleIds, s.AddRole(hubspot.Role{Name: fmt.Sprintf("Role %d", i)}).Id)
+	}
+	for i := range teams {
+		teamIds = append(teamIds, s.AddTeam(hubspot.Team{Name: fmt.Sprintf("Team %d", i)}).Id)
+	}
+	for i := range users {
+		s.AddUser(hubspot.User{
+			Email:      fmt.Sprintf("user%d@example.com", i),
+			RoleIDs:    []string{roleIds[i%roles]},
+			TeamId:     teamIds[i%teams],
+			SuperAdmin: i == 0,
+		})
+	}
+
+	return s
+}
+
+// syncGrants computes the grants of the resources like a sync does, starting from a fresh snapshot.
+func syncGrants(b *testing.B, hs *HubSpot, syncers []connectorbuilder.ResourceSyncer, resources [][]*v2.Resource) {
+	b.Helper()
+
+	hs.users.Invalidate()
+	for i, syncer := range syncers {
+		for _, resource := range resources[i] {
+			if _, _, _, err := syncer.Grants(context.Background(), resource, &pagination.Token{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkGrants(b *testing.B, users int) {
+	s := benchmarkServer(b, users, 10, 10)
+
+	hs, err := New(context.Background(), Config{AccessToken: s.Token(), BaseURL: s.BaseURL()})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	parentId := accountId(hubspottest.DefaultPortalId)
+	syncers := []connectorbuilder.ResourceSyncer{
+		accountBuilder(hs.portals, false, false),
+		roleBuilder(hs.portals),
+		teamBuilder(hs.portals, false),
+	}
+	resources := make([][]*v2.Resource, len(syncers))
+	for i, syncer := range syncers {
+		listParentId := parentId
+		if i == 0 {
+			listParentId = nil
+		}
+		resources[i] = listBenchmarkResources(b, syncer, listParentId)
+	}
+
+	requests := len(s.Requests())
+	b.ResetTimer()
+	for range b.N {
+		syncGrants(b, hs, syncers, resources)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(len(s.Requests())-requests)/float64(b.N), "requests/op")
+}
+
+func listBenchmarkResources(b *testing.B, syncer connectorbuilder.ResourceSyncer, parentId *v2.ResourceId) []*v2.Resource {
+	b.Helper()
+
+	resources, _, _, err := syncer.List(context.Background(), parentId, &pagination.Token{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return resources
+}
+
+// BenchmarkGrants100Users measures the account, role and team grants of a sync, all computed
+// from a single snapshot of the users. The requests/op metric stays at the number of
+// user pages whatever the number of roles and teams.
+func BenchmarkGrants100Users(b *testing.B) {
+	benchmarkGrants(b, 100)
+}
+
+func BenchmarkGrants1000Users(b *testing.B) {
+	benchmarkGrants(b, 1000)
+}
+
+func BenchmarkUserSnapshotLoad(b *testing.B) {
+	s := benchmarkServer(b, 1000, 10, 10)
+	snapshot := newUserSnapshot(s.Client())
+
+	b.ResetTimer()
+	for range b.N {
+		snapshot.Invalidate()
+		if _, _, err := snapshot.Users(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package connector
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/conductorone/baton-hubspot/pkg/hubspot"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -9,6 +13,7 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -40,50 +45,324 @@ var (
 			v2.ResourceType_TRAIT_ROLE,
 		},
 	}
+	resourceTypeOwner = &v2.ResourceType{
+		Id:          "owner",
+		DisplayName: "CRM Owner",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+		Annotations: annotationsForUserResourceType(),
+	}
+	resourceTypeApp = &v2.ResourceType{
+		Id:          "app",
+		DisplayName: "App",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_USER,
+		},
+	}
+	resourceTypeBusinessUnit = &v2.ResourceType{
+		Id:          "business_unit",
+		DisplayName: "Business Unit",
+		Traits: []v2.ResourceType_Trait{
+			v2.ResourceType_TRAIT_GROUP,
+		},
+	}
 )
 
 type HubSpot struct {
-	client     *hubspot.Client
-	userStatus bool
+	// client and users are the ones of the primary portal
+	client           *hubspot.Client
+	userStatus       bool
+	lastLogin        bool
+	expandChildTeams bool
+	syncOwners       bool
+	syncSandboxes    bool
+	users            *userSnapshot
+	portals          *portalSet
 }
 
 func (hs *HubSpot) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
-	return []connectorbuilder.ResourceSyncer{
-		accountBuilder(hs.client),
-		teamBuilder(hs.client),
-		userBuilder(hs.client, hs.userStatus),
-		roleBuilder(hs.client),
+	syncers := []connectorbuilder.ResourceSyncer{
+		accountBuilder(hs.portals, hs.syncOwners, hs.syncSandboxes),
+		teamBuilder(hs.portals, hs.expandChildTeams),
+		userBuilder(hs.portals, hs.userStatus, hs.lastLogin),
+		roleBuilder(hs.portals),
+		businessUnitBuilder(hs.portals),
+		appBuilder(hs.portals),
+	}
+
+	if hs.syncOwners {
+		syncers = append(syncers, ownerBuilder(hs.portals))
+	}
+
+	return syncers
+}
+
+// EventFeeds returns the event feeds of the connector.
+func (hs *HubSpot) EventFeeds(ctx context.Context) []connectorbuilder.EventFeed {
+	return []connectorbuilder.EventFeed{
+		newAuditLogFeed(hs.portals),
+		newActivityFeed(hs.portals),
 	}
 }
 
 // Metadata returns metadata about the connector.
 func (hs *HubSpot) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 	return &v2.ConnectorMetadata{
-		DisplayName: "HubSpot",
+		DisplayName:           "HubSpot",
+		AccountCreationSchema: accountCreationSchema(),
 	}, nil
 }
 
-// Validate hits the HubSpot API to verify that the credentials are valid.
+func accountCreationSchema() *v2.ConnectorAccountCreationSchema {
+	sendWelcomeEmail := false
+
+	return &v2.ConnectorAccountCreationSchema{
+		FieldMap: map[string]*v2.ConnectorAccountCreationSchema_Field{
+			"email": {
+				DisplayName: "Email",
+				Required:    true,
+				Description: "Email address of the new HubSpot user.",
+				Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+					StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+				},
+				Placeholder: "user@example.com",
+				Order:       1,
+			},
+			"first_name": {
+				DisplayName: "First name",
+				Required:    false,
+				Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+					StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+				},
+				Order: 2,
+			},
+			"last_name": {
+				DisplayName: "Last name",
+				Required:    false,
+				Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+					StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+				},
+				Order: 3,
+			},
+			"role_id": {
+				DisplayName: "Role ID",
+				Required:    false,
+				Description: "ID of the role assigned to the user.",
+				Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+					StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+				},
+				Order: 4,
+			},
+			"primary_team_id": {
+				DisplayName: "Primary team ID",
+				Required:    false,
+				Description: "ID of the team the user is a primary member of.",
+				Field: &v2.ConnectorAccountCreationSchema_Field_StringField{
+					StringField: &v2.ConnectorAccountCreationSchema_StringField{},
+				},
+				Order: 5,
+			},
+			"send_welcome_email": {
+				DisplayName: "Send welcome email",
+				Required:    false,
+				Description: "Whether HubSpot sends the welcome email to the new user.",
+				Field: &v2.ConnectorAccountCreationSchema_Field_BoolField{
+					BoolField: &v2.ConnectorAccountCreationSchema_BoolField{
+						DefaultValue: &sendWelcomeEmail,
+					},
+				},
+				Order: 6,
+			},
+		},
+	}
+}
+
+// requiredScopes returns the token scopes needed for syncing with the enabled features.
+func (hs *HubSpot) requiredScopes() []string {
+	scopes := []string{"settings.users.read", "settings.users.teams.read"}
+	if hs.userStatus {
+		scopes = append(scopes, "crm.objects.users.read")
+	}
+	if hs.lastLogin {
+		scopes = append(scopes, "account-info.security.read")
+	}
+	if hs.syncOwners {
+		scopes = append(scopes, "crm.objects.owners.read")
+	}
+
+	return scopes
+}
+
+// missingFeatureScopes logs the features that the token of the portal is not allowed to use.
+func (hs *HubSpot) missingFeatureScopes(ctx context.Context, account hubspot.Account, tokenInfo hubspot.TokenInfo) {
+	l := ctxzap.Extract(ctx)
+
+	features := featureScopes
+	// sandboxes are only listed for production accounts
+	if hs.syncSandboxes && account.IsProduction() {
+		features = append(slices.Clip(features), sandboxScope)
+	}
+
+	for _, feature := range features {
+		if missing := missingScopes(tokenInfo.Scopes, feature.scopes); len(missing) > 0 {
+			l.Warn(
+				"hubspot-connector: token is missing scopes needed for "+feature.feature,
+				zap.Int("portal_id", tokenInfo.HubId),
+				zap.Strings("missing_scopes", missing),
+			)
+		}
+	}
+}
+
+// featureScope lists the token scopes used by a feature.
+type featureScope struct {
+	feature string
+	scopes  []string
+}
+
+// featureScopes are the token scopes of features that are not needed for syncing users, teams and roles.
+// A token missing them is still valid, the missing scopes are reported as warnings.
+var featureScopes = []featureScope{
+	{feature: "provisioning", scopes: []string{"settings.users.write", "settings.users.teams.write"}},
+	{feature: "business units", scopes: []string{"business-units-view.read"}},
+	{feature: "audit log and activity events", scopes: []string{"account-info.security.read"}},
+	{feature: "ownership transfer", scopes: []string{
+		"crm.objects.owners.read",
+		"crm.objects.deals.write",
+		"crm.objects.contacts.write",
+		"crm.objects.companies.write",
+		"tickets",
+	}},
+}
+
+// sandboxScope is needed for discovering the sandboxes of a production account,
+// the tokens of sandbox portals do not need it.
+var sandboxScope = featureScope{feature: "sandboxes", scopes: []string{"sandboxes.read"}}
+
+// Validate hits the HubSpot API to verify that the credentials of every portal are valid
+// and that the tokens were granted the scopes needed by the enabled features.
 func (hs *HubSpot) Validate(ctx context.Context) (annotations.Annotations, error) {
-	_, annotations, err := hs.client.GetAccount(ctx)
+	var annos annotations.Annotations
+	for _, p := range hs.portals.portals {
+		portalAnnos, err := hs.validatePortal(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		annos = append(annos, portalAnnos...)
+	}
+
+	return annos, nil
+}
+
+func (hs *HubSpot) validatePortal(ctx context.Context, p *portal) (annotations.Annotations, error) {
+	// a rejected token is reported as Unauthenticated through the status of the API error
+	account, annotations, err := p.client.GetAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get account: %w", err)
+	}
 
+	tokenInfo, _, err := p.client.GetTokenInfo(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "Provided Access Token is invalid")
+		return nil, fmt.Errorf("hubspot-connector: failed to get token scopes: %w", err)
+	}
+
+	missing := missingScopes(tokenInfo.Scopes, hs.requiredScopes())
+	if len(missing) > 0 {
+		return nil, status.Errorf(
+			codes.PermissionDenied,
+			"hubspot-connector: token of portal %d is missing required scopes: %s",
+			tokenInfo.HubId,
+			strings.Join(missing, ", "),
+		)
 	}
 
+	hs.missingFeatureScopes(ctx, account, tokenInfo)
+
 	return annotations, nil
 }
 
+func missingScopes(granted []string, required []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		grantedSet[scope] = true
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !grantedSet[scope] {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+// Config holds the settings of the connector.
+type Config struct {
+	// AccessToken authenticates the requests to the primary portal, unless OAuth credentials are provided.
+	AccessToken string
+	OAuth       *hubspot.OAuthCredentials
+	// PortalTokens are the access tokens of additional portals synced along the primary one.
+	PortalTokens []string
+	// BaseURL of the HubSpot API, hubspot.BaseURL when empty.
+	BaseURL          string
+	UserStatus       bool
+	LastLogin        bool
+	ExpandChildTeams bool
+	SyncOwners       bool
+	// SyncSandboxes lists the sandboxes of a portal under its account, the portal tokens
+	// of sandboxes then sync their users and roles there.
+	SyncSandboxes bool
+	// RetryBudget bounds the time spent retrying a request, requests are not retried when zero.
+	RetryBudget time.Duration
+}
+
 // New returns the HubSpot connector.
-func New(ctx context.Context, accessToken string, userStatus bool) (*HubSpot, error) {
+func New(ctx context.Context, config Config) (*HubSpot, error) {
 	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
 
 	if err != nil {
 		return nil, err
 	}
 
+	retryOptions := hubspot.DefaultRetryOptions()
+	retryOptions.Budget = config.RetryBudget
+	httpClient.Transport = hubspot.NewRetryTransport(httpClient.Transport, retryOptions)
+
+	var opts []hubspot.ClientOption
+	if config.BaseURL != "" {
+		parsedBaseURL, err := hubspot.ParseBaseURL(config.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("hubspot-connector: %w", err)
+		}
+		opts = append(opts, hubspot.WithBaseURL(parsedBaseURL))
+	}
+
+	var client *hubspot.Client
+	if config.OAuth != nil {
+		client = hubspot.NewOAuthClient(*config.OAuth, httpClient, opts...)
+	} else {
+		client = hubspot.NewClient(config.AccessToken, httpClient, opts...)
+	}
+
+	primary := newPortal(client)
+	portals := []*portal{primary}
+	for _, portalToken := range config.PortalTokens {
+		if portalToken == "" {
+			continue
+		}
+		portals = append(portals, newPortal(hubspot.NewClient(portalToken, httpClient, opts...)))
+	}
+
 	return &HubSpot{
-		client:     hubspot.NewClient(accessToken, httpClient),
-		userStatus: userStatus,
+		client:           primary.client,
+		userStatus:       config.UserStatus,
+		lastLogin:        config.LastLogin,
+		expandChildTeams: config.ExpandChildTeams,
+		syncOwners:       config.SyncOwners,
+		syncSandboxes:    config.SyncSandboxes,
+		users:            primary.users,
+		portals:          newPortalSet(portals...),
 	}, nil
 }
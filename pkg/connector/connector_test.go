@@ -0,0 +1,271 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestConnector returns the connector syncing the account of the fake server.
+func newTestConnector(t *testing.T, s *hubspottest.Server) *HubSpot {
+	t.Helper()
+
+	hs, err := New(context.Background(), Config{
+		AccessToken:      s.Token(),
+		BaseURL:          s.BaseURL(),
+		ExpandChildTeams: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hs
+}
+
+// accountId returns the ID of the account resource of the portal, the parent of the other resources.
+func accountId(portalId int) *v2.ResourceId {
+	return &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: strconv.Itoa(portalId)}
+}
+
+// listAll lists the resources of every page.
+func listAll(t *testing.T, syncer connectorbuilder.ResourceSyncer, parentId *v2.ResourceId) []*v2.Resource {
+	t.Helper()
+
+	var rv []*v2.Resource
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("listing did not end")
+		}
+
+		resources, next, _, err := syncer.List(context.Background(), parentId, &pagination.Token{Token: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv = append(rv, resources...)
+
+		if next == "" {
+			return rv
+		}
+		token = next
+	}
+}
+
+// grantsOf returns the grants of the resource as "entitlement ID/principal ID" strings, sorted.
+func grantsOf(t *testing.T, syncer connectorbuilder.ResourceSyncer, resource *v2.Resource) []string {
+	t.Helper()
+
+	var rv []string
+	token := ""
+	for {
+		grants, next, _, err := syncer.Grants(context.Background(), resource, &pagination.Token{Token: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range grants {
+			rv = append(rv, g.Entitlement.Id+"/"+g.Principal.Id.Resource)
+		}
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	slices.Sort(rv)
+
+	return rv
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetScopes("settings.users.read", "settings.users.teams.read")
+	hs := newTestConnector(t, s)
+
+	if _, err := hs.Validate(ctx); err != nil {
+		t.Fatalf("expected the token to be valid, got %v", err)
+	}
+
+	s.SetScopes("settings.users.read")
+	if _, err := hs.Validate(ctx); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a missing scope, got %v", err)
+	}
+
+	s.SetToken("other")
+	if _, err := hs.Validate(ctx); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for a rejected token, got %v", err)
+	}
+}
+
+func TestValidateReturnsAPIErrors(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	hs, err := New(ctx, Config{AccessToken: s.Token(), BaseURL: s.BaseURL()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.InjectFailure(hubspottest.Failure{Path: "/account-info/v3/details", StatusCode: http.StatusInternalServerError})
+	if _, err := hs.Validate(ctx); err == nil || status.Code(err) == codes.Unauthenticated {
+		t.Fatalf("expected the API error rather than Unauthenticated, got %v", err)
+	}
+}
+
+func TestValidateWarnsAboutFeatureScopes(t *testing.T) {
+	var logs bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logs), zap.WarnLevel)
+	ctx := ctxzap.ToContext(context.Background(), zap.New(core))
+
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetScopes("settings.users.read", "settings.users.teams.read", "settings.users.write", "settings.users.teams.write", "account-info.security.read")
+	hs, err := New(ctx, Config{AccessToken: s.Token(), BaseURL: s.BaseURL(), SyncSandboxes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hs.Validate(ctx); err != nil {
+		t.Fatalf("expected the token to be valid without the feature scopes, got %v", err)
+	}
+
+	for _, feature := range []string{"business units", "ownership transfer", "sandboxes"} {
+		if !strings.Contains(logs.String(), "missing scopes needed for "+feature) {
+			t.Fatalf("expected a warning about %s, got %s", feature, logs.String())
+		}
+	}
+	for _, feature := range []string{"provisioning", "audit log"} {
+		if strings.Contains(logs.String(), "missing scopes needed for "+feature) {
+			t.Fatalf("expected no warning about %s, got %s", feature, logs.String())
+		}
+	}
+}
+
+func TestSyncUsersTeamsAndRoles(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	sales := s.AddTeam(hubspot.Team{Name: "Sales", ChildTeams: []hubspot.Team{{Name: "EMEA"}}})
+	emea := sales.ChildTeams[0]
+	marketing := s.AddTeam(hubspot.Team{Name: "Marketing"})
+	admin := s.AddRole(hubspot.Role{Name: "Admin"})
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com", RoleIDs: []string{admin.Id}, TeamId: emea.Id, SecondaryTeamIDs: []string{marketing.Id}})
+	john := s.AddUser(hubspot.User{Email: "john@example.com", SuperAdmin: true, TeamId: marketing.Id})
+
+	hs := newTestConnector(t, s)
+	syncers := make(map[string]connectorbuilder.ResourceSyncer)
+	for _, syncer := range hs.ResourceSyncers(context.Background()) {
+		syncers[syncer.ResourceType(context.Background()).Id] = syncer
+	}
+
+	accounts := listAll(t, syncers[resourceTypeAccount.Id], nil)
+	if len(accounts) != 1 || accounts[0].Id.Resource != accountId(hubspottest.DefaultPortalId).Resource {
+		t.Fatalf("expected the account of the server, got %v", accounts)
+	}
+
+	users := listAll(t, syncers[resourceTypeUser.Id], accountId(hubspottest.DefaultPortalId))
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(users))
+	}
+
+	want := []string{
+		"account:" + accountId(hubspottest.DefaultPortalId).Resource + ":member/" + jane.Id,
+		"account:" + accountId(hubspottest.DefaultPortalId).Resource + ":member/" + john.Id,
+	}
+	if got := grantsOf(t, syncers[resourceTypeAccount.Id], accounts[0]); !slices.Equal(got, want) {
+		t.Fatalf("account grants: got %v, want %v", got, want)
+	}
+
+	roles := listAll(t, syncers[resourceTypeRole.Id], accountId(hubspottest.DefaultPortalId))
+	var roleGrants []string
+	for _, role := range roles {
+		roleGrants = append(roleGrants, grantsOf(t, syncers[resourceTypeRole.Id], role)...)
+	}
+	slices.Sort(roleGrants)
+	want = []string{
+		"role:" + admin.Id + ":member/" + jane.Id,
+		"role:super_admin:member/" + john.Id,
+	}
+	if !slices.Equal(roleGrants, want) {
+		t.Fatalf("role grants: got %v, want %v", roleGrants, want)
+	}
+
+	// child teams are listed under their parent team
+	teams := listAll(t, syncers[resourceTypeTeam.Id], accountId(hubspottest.DefaultPortalId))
+	if len(teams) != 2 {
+		t.Fatalf("expected 2 top level teams, got %d", len(teams))
+	}
+	teams = append(teams, listAll(t, syncers[resourceTypeTeam.Id], &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: sales.Id})...)
+	if len(teams) != 3 {
+		t.Fatalf("expected 3 teams, got %d", len(teams))
+	}
+	teamGrants := make(map[string][]string)
+	for _, team := range teams {
+		teamGrants[team.Id.Resource] = grantsOf(t, syncers[resourceTypeTeam.Id], team)
+	}
+
+	// with child teams expanded, the child team is granted both memberships of its parent team
+	want = []string{
+		"team:" + sales.Id + ":primary-member/" + emea.Id,
+		"team:" + sales.Id + ":secondary-member/" + emea.Id,
+	}
+	if !slices.Equal(teamGrants[sales.Id], want) {
+		t.Fatalf("sales grants: got %v, want %v", teamGrants[sales.Id], want)
+	}
+	if want := []string{"team:" + emea.Id + ":primary-member/" + jane.Id}; !slices.Equal(teamGrants[emea.Id], want) {
+		t.Fatalf("emea grants: got %v, want %v", teamGrants[emea.Id], want)
+	}
+	want = []string{
+		"team:" + marketing.Id + ":primary-member/" + john.Id,
+		"team:" + marketing.Id + ":secondary-member/" + jane.Id,
+	}
+	if !slices.Equal(teamGrants[marketing.Id], want) {
+		t.Fatalf("marketing grants: got %v, want %v", teamGrants[marketing.Id], want)
+	}
+}
+
+func TestValidateDoesNotWarnSandboxPortalsAboutSandboxes(t *testing.T) {
+	var logs bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logs), zap.WarnLevel)
+	ctx := ctxzap.ToContext(context.Background(), zap.New(core))
+
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetAccount(hubspot.Account{Id: hubspottest.DefaultPortalId, Type: hubspot.AccountTypeSandbox})
+	s.SetScopes("settings.users.read", "settings.users.teams.read")
+	hs, err := New(ctx, Config{AccessToken: s.Token(), BaseURL: s.BaseURL(), SyncSandboxes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hs.Validate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logs.String(), "missing scopes needed for provisioning") {
+		t.Fatalf("expected the other features to be checked, got %s", logs.String())
+	}
+	if strings.Contains(logs.String(), "missing scopes needed for sandboxes") {
+		t.Fatalf("expected no warning about sandboxes for a sandbox portal, got %s", logs.String())
+	}
+}
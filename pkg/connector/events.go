@@ -0,0 +1,565 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	auditLogFeedId       = "audit_log_feed"
+	activityFeedId       = "login_activity_feed"
+	eventFeedPageSize    = 100
+	auditLogCategoryUser = "USER"
+	auditLogCategoryTeam = "TEAM"
+	auditLogCategoryRole = "ROLE"
+)
+
+// sourcePosition is the position of an event feed in one source of events of a portal.
+// Events are fetched from OccurredAfter, paging with After, and Latest becomes the next
+// OccurredAfter once all pages are read.
+type sourcePosition struct {
+	OccurredAfter time.Time `json:"occurred_after"`
+	After         string    `json:"after,omitempty"`
+	Latest        time.Time `json:"latest"`
+}
+
+func startPosition(earliestEvent *timestamppb.Timestamp) sourcePosition {
+	var start time.Time
+	if earliestEvent != nil {
+		start = earliestEvent.AsTime()
+	}
+
+	return sourcePosition{OccurredAfter: start, Latest: start}
+}
+
+// advance returns the position following the page with provided next page token.
+func (c *sourcePosition) advance(nextPage string) sourcePosition {
+	next := sourcePosition{
+		OccurredAfter: c.OccurredAfter,
+		After:         nextPage,
+		Latest:        c.Latest,
+	}
+	if nextPage == "" {
+		next.OccurredAfter = c.Latest
+	}
+
+	return next
+}
+
+// observe records the time of a received event, it reports whether the event
+// is new, as events at the position were already returned.
+func (c *sourcePosition) observe(occurredAt time.Time) bool {
+	if occurredAt.After(c.Latest) {
+		c.Latest = occurredAt
+	}
+
+	return c.OccurredAfter.IsZero() || occurredAt.After(c.OccurredAfter)
+}
+
+// unmarshalFeedCursor reads the cursor of the feed from the stream token, it reports
+// whether there was one. Cursors of other feeds are rejected.
+func unmarshalFeedCursor(feedId string, pToken *pagination.StreamToken, cursor interface{ feed() string }) (bool, error) {
+	if pToken == nil || pToken.Cursor == "" {
+		return false, nil
+	}
+
+	if err := json.Unmarshal([]byte(pToken.Cursor), cursor); err != nil {
+		return false, fmt.Errorf("hubspot-connector: failed to parse event cursor: %w", err)
+	}
+	if cursor.feed() != feedId {
+		return false, fmt.Errorf("hubspot-connector: event cursor of feed %q used for feed %s", cursor.feed(), feedId)
+	}
+
+	return true, nil
+}
+
+// nextPortal returns the index of the portal read by the next page of a feed once the portal
+// at provided index is caught up, it reports whether the feed has more events to read.
+func nextPortal(portal int, portals int) (int, bool) {
+	if portal+1 < portals {
+		return portal + 1, true
+	}
+
+	return 0, false
+}
+
+func eventPageSize(pToken *pagination.StreamToken) int {
+	if pToken != nil && pToken.Size > 0 {
+		return pToken.Size
+	}
+
+	return eventFeedPageSize
+}
+
+// accountIdCache resolves the account resource events are reported under.
+type accountIdCache struct {
+	client *hubspot.Client
+	mtx    sync.Mutex
+	id     *v2.ResourceId
+}
+
+func (a *accountIdCache) get(ctx context.Context) (*v2.ResourceId, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.id != nil {
+		return a.id, nil
+	}
+
+	account, _, err := a.client.GetAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get account: %w", err)
+	}
+
+	a.id = &v2.ResourceId{
+		ResourceType: resourceTypeAccount.Id,
+		Resource:     fmt.Sprint(account.Id),
+	}
+
+	return a.id, nil
+}
+
+// auditLogCursor is the durable position of the audit log feed. The portals are read one after
+// the other, each from its own position keyed by its account ID.
+type auditLogCursor struct {
+	Feed string `json:"feed"`
+	// Portal is the index of the portal read by the next page.
+	Portal    int                       `json:"portal"`
+	Start     sourcePosition            `json:"start"`
+	Positions map[string]sourcePosition `json:"positions"`
+}
+
+func (c *auditLogCursor) feed() string {
+	return c.Feed
+}
+
+func parseAuditLogCursor(earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken) (*auditLogCursor, error) {
+	cursor := &auditLogCursor{}
+	ok, err := unmarshalFeedCursor(auditLogFeedId, pToken, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		cursor = &auditLogCursor{Feed: auditLogFeedId, Start: startPosition(earliestEvent)}
+	}
+	if cursor.Positions == nil {
+		cursor.Positions = make(map[string]sourcePosition)
+	}
+
+	return cursor, nil
+}
+
+// position returns the position of the portal, portals read for the first time start at the start of the feed.
+func (c *auditLogCursor) position(accountId string) sourcePosition {
+	if position, ok := c.Positions[accountId]; ok {
+		return position
+	}
+
+	return c.Start
+}
+
+type auditLogFeed struct {
+	portals *portalSet
+}
+
+func (f *auditLogFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return &v2.EventFeedMetadata{
+		Id: auditLogFeedId,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+		},
+	}
+}
+
+// ListEvents converts user, role, team and permission changes from the audit logs of every portal
+// to resource change events, a page reads the audit logs of a single portal.
+func (f *auditLogFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	cursor, err := parseAuditLogCursor(earliestEvent, pToken)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the portals may have changed since the cursor was saved
+	if cursor.Portal >= len(f.portals.portals) {
+		cursor.Portal = 0
+	}
+	p := f.portals.portals[cursor.Portal]
+
+	accountId, err := p.account.get(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	position := cursor.position(accountId.Resource)
+	auditLogs, nextPage, annos, err := p.client.GetAuditLogs(ctx, hubspot.GetActivityVars{
+		Limit:         eventPageSize(pToken),
+		After:         position.After,
+		OccurredAfter: position.OccurredAfter,
+	})
+	if err != nil {
+		return nil, nil, annos, fmt.Errorf("hubspot-connector: failed to list audit logs of portal %s: %w", accountId.Resource, err)
+	}
+
+	var rv []*v2.Event
+	for _, auditLog := range auditLogs {
+		if !position.observe(auditLog.OccurredAt) {
+			continue
+		}
+
+		resourceType := auditLogResourceType(&auditLog)
+		if resourceType == nil || auditLog.TargetObjectId == "" {
+			continue
+		}
+
+		id, err := f.portals.qualifiedId(ctx, p, auditLog.TargetObjectId)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		resourceId := &v2.ResourceId{ResourceType: resourceType.Id, Resource: id}
+
+		rv = append(rv, &v2.Event{
+			Id:         auditLog.Id,
+			OccurredAt: timestamppb.New(auditLog.OccurredAt),
+			Event: &v2.Event_ResourceChangeEvent{
+				ResourceChangeEvent: &v2.ResourceChangeEvent{
+					ResourceId:       resourceId,
+					ParentResourceId: accountId,
+				},
+			},
+		})
+	}
+
+	cursor.Positions[accountId.Resource] = position.advance(nextPage)
+	hasMore := nextPage != ""
+	if !hasMore {
+		cursor.Portal, hasMore = nextPortal(cursor.Portal, len(f.portals.portals))
+	}
+
+	nextCursor, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return rv, &pagination.StreamState{Cursor: string(nextCursor), HasMore: hasMore}, annos, nil
+}
+
+// auditLogResourceType returns the type of the resource changed by the audit log,
+// or nil when the change does not affect access synced by the connector.
+func auditLogResourceType(auditLog *hubspot.AuditLog) *v2.ResourceType {
+	category := strings.ToUpper(auditLog.Category)
+
+	switch {
+	case strings.HasPrefix(category, auditLogCategoryTeam):
+		return resourceTypeTeam
+	case strings.HasPrefix(category, auditLogCategoryRole):
+		return resourceTypeRole
+	case strings.HasPrefix(category, auditLogCategoryUser), strings.Contains(category, "PERMISSION"):
+		// role and permission assignments are recorded against the user
+		return resourceTypeUser
+	default:
+		return nil
+	}
+}
+
+func newAuditLogFeed(portals *portalSet) *auditLogFeed {
+	return &auditLogFeed{
+		portals: portals,
+	}
+}
+
+const (
+	activityStageLogin    = "LOGIN"
+	activityStageSecurity = "SECURITY"
+)
+
+// activityCursor is the durable position of the activity feed. The portals are read one after
+// the other, the login activity of a portal first and its security activity afterwards, each
+// from its own position keyed by the account ID of the portal.
+type activityCursor struct {
+	Feed string `json:"feed"`
+	// Portal and Stage select the portal and the source read by the next page.
+	Portal   int                       `json:"portal"`
+	Stage    string                    `json:"stage"`
+	Start    sourcePosition            `json:"start"`
+	Login    map[string]sourcePosition `json:"login"`
+	Security map[string]sourcePosition `json:"security"`
+}
+
+func (c *activityCursor) feed() string {
+	return c.Feed
+}
+
+func parseActivityCursor(earliestEvent *timestamppb.Timestamp, pToken *pagination.StreamToken) (*activityCursor, error) {
+	cursor := &activityCursor{}
+	ok, err := unmarshalFeedCursor(activityFeedId, pToken, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		cursor = &activityCursor{Feed: activityFeedId, Stage: activityStageLogin, Start: startPosition(earliestEvent)}
+	}
+	if cursor.Login == nil {
+		cursor.Login = make(map[string]sourcePosition)
+	}
+	if cursor.Security == nil {
+		cursor.Security = make(map[string]sourcePosition)
+	}
+
+	return cursor, nil
+}
+
+// position returns the position of the portal in the source, portals read for the first time start at the start of the feed.
+func (c *activityCursor) position(positions map[string]sourcePosition, accountId string) sourcePosition {
+	if position, ok := positions[accountId]; ok {
+		return position
+	}
+
+	return c.Start
+}
+
+type activityFeed struct {
+	portals *portalSet
+}
+
+func (f *activityFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return &v2.EventFeedMetadata{
+		Id: activityFeedId,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_USAGE,
+		},
+	}
+}
+
+// ListEvents streams the login attempts followed by the security activity of every portal as usage events,
+// a page reads a single source of a single portal.
+func (f *activityFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	cursor, err := parseActivityCursor(earliestEvent, pToken)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the portals may have changed since the cursor was saved
+	if cursor.Portal >= len(f.portals.portals) {
+		cursor.Portal, cursor.Stage = 0, activityStageLogin
+	}
+	p := f.portals.portals[cursor.Portal]
+
+	accountId, err := p.account.get(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	limit := eventPageSize(pToken)
+
+	var (
+		rv       []*v2.Event
+		nextPage string
+		annos    annotations.Annotations
+	)
+	switch cursor.Stage {
+	case activityStageSecurity:
+		position := cursor.position(cursor.Security, accountId.Resource)
+		rv, nextPage, annos, err = f.securityEvents(ctx, p, &position, limit, accountId)
+		if err != nil {
+			return nil, nil, annos, err
+		}
+		cursor.Security[accountId.Resource] = position.advance(nextPage)
+	default:
+		position := cursor.position(cursor.Login, accountId.Resource)
+		rv, nextPage, annos, err = f.loginEvents(ctx, p, &position, limit, accountId)
+		if err != nil {
+			return nil, nil, annos, err
+		}
+		cursor.Login[accountId.Resource] = position.advance(nextPage)
+	}
+
+	// the stream is caught up once both sources of every portal are fully read
+	hasMore := nextPage != ""
+	if !hasMore {
+		if cursor.Stage == activityStageSecurity {
+			cursor.Stage = activityStageLogin
+			cursor.Portal, hasMore = nextPortal(cursor.Portal, len(f.portals.portals))
+		} else {
+			cursor.Stage, hasMore = activityStageSecurity, true
+		}
+	}
+
+	nextCursor, err := json.Marshal(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return rv, &pagination.StreamState{Cursor: string(nextCursor), HasMore: hasMore}, annos, nil
+}
+
+func (f *activityFeed) loginEvents(
+	ctx context.Context,
+	p *portal,
+	cursor *sourcePosition,
+	limit int,
+	accountId *v2.ResourceId,
+) ([]*v2.Event, string, annotations.Annotations, error) {
+	logins, nextPage, annos, err := p.client.GetLoginActivity(ctx, hubspot.GetActivityVars{
+		Limit:         limit,
+		After:         cursor.After,
+		OccurredAfter: cursor.OccurredAfter,
+	})
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("hubspot-connector: failed to list login activity of portal %s: %w", accountId.Resource, err)
+	}
+
+	var rv []*v2.Event
+	for _, login := range logins {
+		if !cursor.observe(login.LoginAt) || login.UserId == "" {
+			continue
+		}
+
+		userTraitOptions := []rs.UserTraitOption{
+			rs.WithUserProfile(map[string]interface{}{
+				"activity_type": "LOGIN",
+				"succeeded":     login.Succeeded,
+				"ip_address":    login.IpAddress,
+				"location":      login.Location,
+				"country_code":  login.CountryCode,
+				"user_agent":    login.UserAgent,
+			}),
+		}
+
+		// only successful attempts are logins of the user
+		if login.Succeeded {
+			userTraitOptions = append(userTraitOptions, rs.WithLastLogin(login.LoginAt))
+		}
+
+		actorId, err := f.portals.userResourceId(ctx, p, login.UserId)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		event, err := activityEvent(login.Id, login.LoginAt, actorId, login.Email, accountId, userTraitOptions)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, event)
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+func (f *activityFeed) securityEvents(
+	ctx context.Context,
+	p *portal,
+	cursor *sourcePosition,
+	limit int,
+	accountId *v2.ResourceId,
+) ([]*v2.Event, string, annotations.Annotations, error) {
+	activities, nextPage, annos, err := p.client.GetSecurityActivity(ctx, hubspot.GetActivityVars{
+		Limit:         limit,
+		After:         cursor.After,
+		OccurredAfter: cursor.OccurredAfter,
+	})
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("hubspot-connector: failed to list security activity of portal %s: %w", accountId.Resource, err)
+	}
+
+	var rv []*v2.Event
+	for _, activity := range activities {
+		userId := activity.UserId
+		if userId == "" {
+			userId = activity.ActingUser.UserId
+		}
+
+		if !cursor.observe(activity.CreatedAt) || userId == "" {
+			continue
+		}
+
+		userTraitOptions := []rs.UserTraitOption{
+			rs.WithUserProfile(map[string]interface{}{
+				"activity_type": activity.Type,
+				"ip_address":    activity.IpAddress,
+				"location":      activity.Location,
+			}),
+		}
+
+		actorId, err := f.portals.userResourceId(ctx, p, userId)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		event, err := activityEvent(activity.Id, activity.CreatedAt, actorId, activity.ActingUser.UserEmail, accountId, userTraitOptions)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, event)
+	}
+
+	return rv, nextPage, annos, nil
+}
+
+// activityEvent creates a usage event of the user on the account, the activity
+// details are reported on the user trait of the actor.
+func activityEvent(
+	id string,
+	occurredAt time.Time,
+	actorId *v2.ResourceId,
+	email string,
+	accountId *v2.ResourceId,
+	userTraitOptions []rs.UserTraitOption,
+) (*v2.Event, error) {
+	if email != "" {
+		userTraitOptions = append(userTraitOptions, rs.WithEmail(email, true))
+	}
+
+	displayName := email
+	if displayName == "" {
+		displayName = actorId.Resource
+	}
+
+	actor, err := rs.NewUserResource(
+		displayName,
+		resourceTypeUser,
+		actorId.Resource,
+		userTraitOptions,
+		rs.WithParentResourceID(accountId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v2.Event{
+		Id:         id,
+		OccurredAt: timestamppb.New(occurredAt),
+		Event: &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				TargetResource: &v2.Resource{Id: accountId},
+				ActorResource:  actor,
+			},
+		},
+	}, nil
+}
+
+func newActivityFeed(portals *portalSet) *activityFeed {
+	return &activityFeed{
+		portals: portals,
+	}
+}
@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// readFeed reads the feed from the cursor until it is caught up, it returns the IDs
+// of the events and the cursor to resume from.
+func readFeed(t *testing.T, feed connectorbuilder.EventFeed, earliestEvent time.Time, cursor string) ([]string, string) {
+	t.Helper()
+
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("feed did not catch up")
+		}
+
+		events, state, _, err := feed.ListEvents(
+			context.Background(),
+			timestamppb.New(earliestEvent),
+			&pagination.StreamToken{Size: 2, Cursor: cursor},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range events {
+			ids = append(ids, event.Id)
+		}
+
+		cursor = state.Cursor
+		if !state.HasMore {
+			return ids, cursor
+		}
+	}
+}
+
+// twoPortals returns the connector syncing the primary and the other server as portals.
+func twoPortals(t *testing.T) (*hubspottest.Server, *hubspottest.Server, *portalSet) {
+	t.Helper()
+
+	primary := hubspottest.NewServer()
+	t.Cleanup(primary.Close)
+	other := hubspottest.NewServer()
+	t.Cleanup(other.Close)
+	other.SetAccount(hubspot.Account{Id: 67890, Type: "STANDARD"})
+
+	return primary, other, newPortalSet(newPortal(primary.Client()), newPortal(other.Client()))
+}
+
+func TestAuditLogFeedReadsEveryPortal(t *testing.T) {
+	primary, other, portals := twoPortals(t)
+	feed := newAuditLogFeed(portals)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"p1", "p2", "p3"} {
+		primary.AddAuditLog(hubspot.AuditLog{Id: id, Category: "USER", TargetObjectId: "1", OccurredAt: start.Add(time.Duration(i+1) * time.Minute)})
+	}
+	other.AddAuditLog(hubspot.AuditLog{Id: "o1", Category: "TEAM", TargetObjectId: "2", OccurredAt: start.Add(time.Minute)})
+	// changes outside of users, teams and roles are skipped
+	other.AddAuditLog(hubspot.AuditLog{Id: "o2", Category: "CONTACT", TargetObjectId: "3", OccurredAt: start.Add(2 * time.Minute)})
+
+	ids, cursor := readFeed(t, feed, start, "")
+	slices.Sort(ids)
+	if want := []string{"o1", "p1", "p2", "p3"}; !slices.Equal(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+
+	// resuming returns only the events that occurred since
+	primary.AddAuditLog(hubspot.AuditLog{Id: "p4", Category: "ROLE", TargetObjectId: "4", OccurredAt: start.Add(time.Hour)})
+	other.AddAuditLog(hubspot.AuditLog{Id: "o3", Category: "USER", TargetObjectId: "5", OccurredAt: start.Add(time.Hour)})
+
+	ids, _ = readFeed(t, feed, start, cursor)
+	slices.Sort(ids)
+	if want := []string{"o3", "p4"}; !slices.Equal(ids, want) {
+		t.Fatalf("after resuming got %v, want %v", ids, want)
+	}
+}
+
+func TestAuditLogFeedEventsAreUnderTheirPortal(t *testing.T) {
+	_, other, portals := twoPortals(t)
+	feed := newAuditLogFeed(portals)
+
+	other.AddAuditLog(hubspot.AuditLog{Id: "o1", Category: "TEAM", TargetObjectId: "2", OccurredAt: time.Now()})
+
+	// the first page reads the primary portal, which has no events
+	_, state, _, err := feed.ListEvents(context.Background(), nil, &pagination.StreamToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.HasMore {
+		t.Fatal("expected the other portal to be read next")
+	}
+
+	events, state, _, err := feed.ListEvents(context.Background(), nil, &pagination.StreamToken{Cursor: state.Cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.HasMore || len(events) != 1 {
+		t.Fatalf("expected the event of the other portal, got %v", events)
+	}
+	if parent := events[0].GetResourceChangeEvent().GetParentResourceId().GetResource(); parent != "67890" {
+		t.Fatalf("expected the event under account 67890, got %s", parent)
+	}
+}
+
+func TestAuditLogFeedRejectsOtherCursors(t *testing.T) {
+	_, _, portals := twoPortals(t)
+	feed := newAuditLogFeed(portals)
+
+	_, _, _, err := feed.ListEvents(context.Background(), nil, &pagination.StreamToken{Cursor: `{"feed":"other"}`})
+	if err == nil {
+		t.Fatal("expected the cursor of another feed to be rejected")
+	}
+}
+
+func TestActivityFeedReadsEveryPortal(t *testing.T) {
+	primary, other, portals := twoPortals(t)
+	feed := newActivityFeed(portals)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary.AddLoginActivity(hubspot.LoginActivity{Id: "pl1", UserId: "1", LoginAt: start.Add(time.Minute), Succeeded: true})
+	primary.AddLoginActivity(hubspot.LoginActivity{Id: "pl2", UserId: "1", LoginAt: start.Add(2 * time.Minute)})
+	primary.AddLoginActivity(hubspot.LoginActivity{Id: "pl3", UserId: "2", LoginAt: start.Add(3 * time.Minute), Succeeded: true})
+	primary.AddSecurityActivity(hubspot.SecurityActivity{Id: "ps1", Type: "PASSWORD_CHANGE", UserId: "1", CreatedAt: start.Add(time.Minute)})
+	other.AddLoginActivity(hubspot.LoginActivity{Id: "ol1", UserId: "3", LoginAt: start.Add(time.Minute), Succeeded: true})
+	other.AddSecurityActivity(hubspot.SecurityActivity{Id: "os1", Type: "TWO_FACTOR_DISABLED", ActingUser: hubspot.ActingUser{UserId: "3"}, CreatedAt: start.Add(time.Minute)})
+
+	ids, cursor := readFeed(t, feed, start, "")
+	slices.Sort(ids)
+	if want := []string{"ol1", "os1", "pl1", "pl2", "pl3", "ps1"}; !slices.Equal(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+
+	// resuming returns only the activity that occurred since
+	primary.AddSecurityActivity(hubspot.SecurityActivity{Id: "ps2", Type: "PASSWORD_CHANGE", UserId: "2", CreatedAt: start.Add(time.Hour)})
+	other.AddLoginActivity(hubspot.LoginActivity{Id: "ol2", UserId: "3", LoginAt: start.Add(time.Hour)})
+
+	ids, _ = readFeed(t, feed, start, cursor)
+	slices.Sort(ids)
+	if want := []string{"ol2", "ps2"}; !slices.Equal(ids, want) {
+		t.Fatalf("after resuming got %v, want %v", ids, want)
+	}
+}
+
+func TestActivityFeedReportsTheActivityOnTheActor(t *testing.T) {
+	primary, _, portals := twoPortals(t)
+	feed := newActivityFeed(portals)
+
+	loginAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	primary.AddLoginActivity(hubspot.LoginActivity{Id: "ok", UserId: "1", Email: "jane@example.com", LoginAt: loginAt, Succeeded: true, IpAddress: "10.0.0.1"})
+	primary.AddLoginActivity(hubspot.LoginActivity{Id: "failed", UserId: "1", LoginAt: loginAt.Add(time.Minute)})
+
+	events, _, _, err := feed.ListEvents(context.Background(), nil, &pagination.StreamToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected both login attempts, got %d events", len(events))
+	}
+
+	for _, event := range events {
+		usage := event.GetUsageEvent()
+		if len(event.Annotations) != 0 {
+			t.Fatalf("%s: expected no annotations, got %v", event.Id, event.Annotations)
+		}
+		if target := usage.GetTargetResource().GetId().GetResource(); target != "12345" {
+			t.Fatalf("%s: expected the account as target, got %s", event.Id, target)
+		}
+
+		userTrait, err := rs.GetUserTrait(usage.GetActorResource())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if activityType, _ := rs.GetProfileStringValue(userTrait.Profile, "activity_type"); activityType != "LOGIN" {
+			t.Fatalf("%s: expected a LOGIN activity, got %q", event.Id, activityType)
+		}
+
+		switch event.Id {
+		case "ok":
+			if !userTrait.GetLastLogin().AsTime().Equal(loginAt) {
+				t.Fatalf("expected the successful login as last login, got %v", userTrait.GetLastLogin())
+			}
+			if ip, _ := rs.GetProfileStringValue(userTrait.Profile, "ip_address"); ip != "10.0.0.1" {
+				t.Fatalf("expected the IP address on the profile, got %q", ip)
+			}
+		case "failed":
+			if userTrait.GetLastLogin() != nil {
+				t.Fatalf("expected no last login for a failed attempt, got %v", userTrait.GetLastLogin())
+			}
+		}
+	}
+}
+
+func TestActivityFeedRejectsAuditLogCursors(t *testing.T) {
+	_, _, portals := twoPortals(t)
+
+	_, state, _, err := newAuditLogFeed(portals).ListEvents(context.Background(), nil, &pagination.StreamToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = newActivityFeed(portals).ListEvents(context.Background(), nil, &pagination.StreamToken{Cursor: state.Cursor})
+	if err == nil {
+		t.Fatal("expected the cursor of the audit log feed to be rejected")
+	}
+}
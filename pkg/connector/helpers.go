@@ -1,12 +1,18 @@
 package connector
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
 
 	"github.com/conductorone/baton-hubspot/pkg/hubspot"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -47,6 +53,50 @@ func parsePageToken(i string, resourceID *v2.ResourceId) (*pagination.Bag, error
 	return b, nil
 }
 
+// skipUnavailable reports whether the error means the feature is not available to the account,
+// e.g. it requires an add-on or a scope the token lacks, in which case the sync goes on without it.
+func skipUnavailable(ctx context.Context, err error, feature string, accountId string) bool {
+	if !hubspot.IsForbidden(err) && !hubspot.IsNotFound(err) {
+		return false
+	}
+
+	ctxzap.Extract(ctx).Warn(
+		fmt.Sprintf("hubspot-connector: %s are not available, skipping them", feature),
+		zap.String("account_id", accountId),
+		zap.Error(err),
+	)
+
+	return true
+}
+
+// offsetPage returns the bounds of the current page over total items and
+// the page token pointing to the next page, if any.
+func offsetPage(bag *pagination.Bag, total int) (int, int, string, error) {
+	start := 0
+	if bag.PageToken() != "" {
+		var err error
+		start, err = strconv.Atoi(bag.PageToken())
+		if err != nil {
+			return 0, 0, "", fmt.Errorf("hubspot-connector: invalid page token: %w", err)
+		}
+	}
+
+	start = min(max(start, 0), total)
+	end := min(start+ResourcesPageSize, total)
+
+	var nextPage string
+	if end < total {
+		nextPage = strconv.Itoa(end)
+	}
+
+	pageToken, err := bag.NextToken(nextPage)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	return start, end, pageToken, nil
+}
+
 func parseUserPaginationToken(token UsersPaginationToken, bag *pagination.Bag) (string, error) {
 	jsonToken, err := json.Marshal(token)
 	if err != nil {
@@ -100,6 +150,33 @@ func filterUsersBySuperAdmin(users []hubspot.User) []hubspot.User {
 	return superAdmins
 }
 
+func containsRole(rIDs []string, targetRole string) bool {
+	for _, id := range rIDs {
+		if id == targetRole {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateUserPayload builds an update payload that keeps the current role and
+// team assignments of the user, as HubSpot removes any assignment omitted from the request.
+func updateUserPayload(user *hubspot.User) *hubspot.UpdateUserPayload {
+	payload := &hubspot.UpdateUserPayload{
+		PrimaryTeamId: user.TeamId,
+		// an empty list is sent rather than null to remove the last secondary team
+		SecondaryTeamIDs: append([]string{}, user.SecondaryTeamIDs...),
+	}
+
+	// there is only one role supported so far
+	if len(user.RoleIDs) != 0 {
+		payload.RoleId = user.RoleIDs[0]
+	}
+
+	return payload
+}
+
 func containsTeam(tIDs []string, targetTeam string) bool {
 	for _, id := range tIDs {
 		if id == targetTeam {
@@ -122,9 +199,75 @@ func removeTeam(tIDs []string, targetTeam string) []string {
 	return tv
 }
 
+// teamHierarchy indexes the teams by ID, including nested child teams,
+// and returns the IDs of the top level teams in the order received.
+func teamHierarchy(teams []hubspot.Team) (map[string]hubspot.Team, []string) {
+	index := make(map[string]hubspot.Team)
+	childIDs := make(map[string]bool)
+
+	var walk func(teams []hubspot.Team)
+	walk = func(teams []hubspot.Team) {
+		for _, team := range teams {
+			// prefer the full team over a child team reference
+			if _, ok := index[team.Id]; !ok || team.UserIDs != nil || team.SecondaryUserIDs != nil {
+				index[team.Id] = team
+			}
+			for _, child := range team.ChildTeams {
+				childIDs[child.Id] = true
+			}
+			walk(team.ChildTeams)
+		}
+	}
+	walk(teams)
+
+	var topLevel []string
+	for _, team := range teams {
+		if !childIDs[team.Id] {
+			topLevel = append(topLevel, team.Id)
+		}
+	}
+
+	return index, topLevel
+}
+
 func getUserResourceId(userId string) *v2.ResourceId {
 	return &v2.ResourceId{
 		ResourceType: resourceTypeUser.Id,
 		Resource:     userId,
 	}
 }
+
+// createUserPayload builds the user creation request from the account info provided by Baton.
+func createUserPayload(accountInfo *v2.AccountInfo) (*hubspot.CreateUserPayload, error) {
+	profile := accountInfo.GetProfile()
+
+	email, ok := rs.GetProfileStringValue(profile, "email")
+	if !ok || email == "" {
+		for _, e := range accountInfo.GetEmails() {
+			email = e.GetAddress()
+			if e.GetIsPrimary() {
+				break
+			}
+		}
+	}
+	if email == "" {
+		email = accountInfo.GetLogin()
+	}
+	if email == "" {
+		return nil, fmt.Errorf("hubspot-connector: email is required to create a user")
+	}
+
+	payload := &hubspot.CreateUserPayload{
+		Email: email,
+	}
+	payload.FirstName, _ = rs.GetProfileStringValue(profile, "first_name")
+	payload.LastName, _ = rs.GetProfileStringValue(profile, "last_name")
+	payload.RoleId, _ = rs.GetProfileStringValue(profile, "role_id")
+	payload.PrimaryTeamId, _ = rs.GetProfileStringValue(profile, "primary_team_id")
+
+	if v, ok := profile.GetFields()["send_welcome_email"]; ok {
+		payload.SendWelcomeEmail = v.GetBoolValue()
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+const (
+	PageTypeActiveOwners   = "ACTIVE_OWNERS"
+	PageTypeArchivedOwners = "ARCHIVED_OWNERS"
+)
+
+type ownerResourceType struct {
+	resourceType *v2.ResourceType
+	portals      *portalSet
+}
+
+func (o *ownerResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return o.resourceType
+}
+
+// Create a new connector resource for an HubSpot CRM owner.
+// Archived owners belong to removed users, yet they can still own CRM records.
+func ownerResource(owner *hubspot.Owner, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"login":      owner.Email,
+		"owner_id":   owner.Id,
+		"first_name": owner.FirstName,
+		"last_name":  owner.LastName,
+		"archived":   owner.Archived,
+	}
+
+	if owner.UserId != 0 {
+		profile["user_id"] = strconv.Itoa(owner.UserId)
+	}
+
+	ownerState := v2.UserTrait_Status_STATUS_ENABLED
+	if owner.Archived {
+		ownerState = v2.UserTrait_Status_STATUS_DELETED
+	}
+
+	userTraitOptions := []rs.UserTraitOption{
+		rs.WithUserProfile(profile),
+		rs.WithStatus(ownerState),
+	}
+
+	if owner.Email != "" {
+		userTraitOptions = append(userTraitOptions, rs.WithEmail(owner.Email, true))
+	}
+
+	if !owner.CreatedAt.IsZero() {
+		userTraitOptions = append(userTraitOptions, rs.WithCreatedAt(owner.CreatedAt))
+	}
+
+	displayName := owner.Email
+	if displayName == "" {
+		displayName = owner.Id
+	}
+
+	resource, err := rs.NewUserResource(
+		displayName,
+		resourceTypeOwner,
+		owner.Id,
+		userTraitOptions,
+		rs.WithParentResourceID(parentResourceID),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (o *ownerResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentId == nil {
+		return nil, "", nil, nil
+	}
+
+	p, err := o.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeOwner.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	ownerPageToken, err := unmarshalUserPageToken(bag.PageToken())
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to unmarshal the token %w", err)
+	}
+
+	// active owners are listed first, archived ones afterwards
+	archived := ownerPageToken.Type == PageTypeArchivedOwners
+	owners, nextToken, annotations, err := p.client.GetOwners(ctx, hubspot.GetOwnersVars{
+		Limit:    ResourcesPageSize,
+		After:    ownerPageToken.Page,
+		Archived: archived,
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list owners: %w", err)
+	}
+
+	var pageToken string
+	switch {
+	case nextToken != "" && archived:
+		pageToken, err = parseUserPaginationToken(UsersPaginationToken{Page: nextToken, Type: PageTypeArchivedOwners}, bag)
+	case nextToken != "":
+		pageToken, err = parseUserPaginationToken(UsersPaginationToken{Page: nextToken, Type: PageTypeActiveOwners}, bag)
+	case !archived:
+		pageToken, err = parseUserPaginationToken(UsersPaginationToken{Page: "", Type: PageTypeArchivedOwners}, bag)
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, owner := range owners {
+		ownerCopy := owner
+
+		or, err := ownerResource(&ownerCopy, parentId)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, or)
+	}
+
+	return rv, pageToken, annotations, nil
+}
+
+func (o *ownerResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func (o *ownerResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func ownerBuilder(portals *portalSet) *ownerResourceType {
+	return &ownerResourceType{
+		resourceType: resourceTypeOwner,
+		portals:      portals,
+	}
+}
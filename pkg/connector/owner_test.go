@@ -0,0 +1,88 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOwnersList(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	// more active owners than fit in a page, then the archived owners of removed users
+	for i := 0; i < ResourcesPageSize+10; i++ {
+		s.AddOwner(hubspot.Owner{Email: fmt.Sprintf("owner%d@example.com", i), UserId: i + 1})
+	}
+	archived := s.AddOwner(hubspot.Owner{Email: "former@example.com", Archived: true})
+
+	owners := listAll(t, ownerBuilder(newTestConnector(t, s).portals), accountId(hubspottest.DefaultPortalId))
+	if len(owners) != ResourcesPageSize+11 {
+		t.Fatalf("expected %d owners, got %d", ResourcesPageSize+11, len(owners))
+	}
+
+	seen := make(map[string]bool)
+	for _, owner := range owners {
+		if seen[owner.Id.Resource] {
+			t.Fatalf("owner %s listed twice", owner.Id.Resource)
+		}
+		seen[owner.Id.Resource] = true
+	}
+
+	last := owners[len(owners)-1]
+	if last.Id.Resource != archived.Id {
+		t.Fatalf("expected the archived owner to be listed last, got %s", last.Id.Resource)
+	}
+	trait, err := rs.GetUserTrait(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trait.Status.Status != v2.UserTrait_Status_STATUS_DELETED {
+		t.Fatalf("expected the archived owner to be deleted, got %v", trait.Status.Status)
+	}
+	if userId := trait.Profile.Fields["user_id"]; userId != nil {
+		t.Fatalf("expected no user ID for the archived owner, got %v", userId)
+	}
+
+	trait, err = rs.GetUserTrait(owners[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trait.Status.Status != v2.UserTrait_Status_STATUS_ENABLED || trait.Profile.Fields["user_id"].GetStringValue() != "1" {
+		t.Fatalf("expected an enabled owner of user 1, got %v %v", trait.Status.Status, trait.Profile.Fields["user_id"])
+	}
+}
+
+func TestOwnersScopeIsRequiredWhenSynced(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetScopes("settings.users.read", "settings.users.teams.read")
+
+	hs, err := New(ctx, Config{AccessToken: s.Token(), BaseURL: s.BaseURL(), SyncOwners: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hs.Validate(ctx); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied without crm.objects.owners.read, got %v", err)
+	}
+
+	s.SetScopes("settings.users.read", "settings.users.teams.read", "crm.objects.owners.read")
+	if _, err := hs.Validate(ctx); err != nil {
+		t.Fatalf("expected the token to be valid, got %v", err)
+	}
+
+	// the scope is not required when owners are not synced
+	s.SetScopes("settings.users.read", "settings.users.teams.read")
+	if _, err := newTestConnector(t, s).Validate(ctx); err != nil {
+		t.Fatalf("expected the token to be valid without owners, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// portal is a HubSpot account synced by the connector, with the client authenticated for it.
+type portal struct {
+	client        *hubspot.Client
+	users         *userSnapshot
+	businessUnits *businessUnitSnapshot
+	sandboxes     *sandboxSnapshot
+	account       *accountIdCache
+}
+
+func newPortal(client *hubspot.Client) *portal {
+	users := newUserSnapshot(client)
+
+	return &portal{
+		client:        client,
+		users:         users,
+		businessUnits: newBusinessUnitSnapshot(client, users),
+		sandboxes:     newSandboxSnapshot(client),
+		account:       &accountIdCache{client: client},
+	}
+}
+
+// portalSet holds the portals synced by the connector. The first one is the primary portal,
+// the one account provisioning and the ownership transfer operate on.
+//
+// Every portal is synced as an account resource, the resources of a portal are listed under
+// its account, or under their parent team for child teams, and are routed back to the portal
+// through their parent resource. HubSpot IDs are only unique within a portal, so the resource
+// IDs of users, teams, roles, business units and apps are qualified with the account ID outside
+// of the primary portal, and users and teams are routed through their resource ID. The account
+// of a sandbox portal is listed under the account of its production portal.
+type portalSet struct {
+	portals []*portal
+}
+
+func newPortalSet(portals ...*portal) *portalSet {
+	return &portalSet{portals: portals}
+}
+
+func (s *portalSet) primary() *portal {
+	return s.portals[0]
+}
+
+func (s *portalSet) isPrimary(p *portal) bool {
+	return p == s.primary()
+}
+
+// forAccount returns the portal of the account.
+func (s *portalSet) forAccount(ctx context.Context, accountId string) (*portal, error) {
+	if len(s.portals) == 1 {
+		return s.primary(), nil
+	}
+
+	p, err := s.findAccount(ctx, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, status.Errorf(codes.NotFound, "hubspot-connector: no credentials for portal %s", accountId)
+	}
+
+	return p, nil
+}
+
+// findAccount returns the portal of the account, or nil when the connector has no credentials
+// for it, as for sandboxes discovered under a production account.
+func (s *portalSet) findAccount(ctx context.Context, accountId string) (*portal, error) {
+	for _, p := range s.portals {
+		id, err := p.account.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if id.Resource == accountId {
+			return p, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// forTeam returns the portal of the team resource, through the account ID its resource ID is qualified with.
+func (s *portalSet) forTeam(ctx context.Context, resourceId string) (*portal, error) {
+	_, accountId := parseQualifiedId(resourceId)
+	if accountId == "" {
+		return s.primary(), nil
+	}
+
+	return s.forAccount(ctx, accountId)
+}
+
+// forParent returns the portal of the resources listed under the parent resource.
+// Resources without a parent belong to the primary portal.
+func (s *portalSet) forParent(ctx context.Context, parentId *v2.ResourceId) (*portal, error) {
+	if len(s.portals) == 1 || parentId == nil {
+		return s.primary(), nil
+	}
+
+	switch parentId.ResourceType {
+	case resourceTypeAccount.Id:
+		return s.forAccount(ctx, parentId.Resource)
+	case resourceTypeTeam.Id:
+		return s.forTeam(ctx, parentId.Resource)
+	default:
+		return nil, fmt.Errorf("hubspot-connector: unexpected parent resource type %s", parentId.ResourceType)
+	}
+}
+
+// forResource returns the portal the resource belongs to.
+func (s *portalSet) forResource(ctx context.Context, resource *v2.Resource) (*portal, error) {
+	return s.forParent(ctx, resource.GetParentResourceId())
+}
+
+// qualifiedId returns the resource ID of a HubSpot object only unique within the portal, such as
+// users, teams, roles and apps. It is qualified with the account ID for every portal other than the primary one.
+func (s *portalSet) qualifiedId(ctx context.Context, p *portal, id string) (string, error) {
+	if s.isPrimary(p) {
+		return id, nil
+	}
+
+	accountId, err := p.account.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return id + ":" + accountId.Resource, nil
+}
+
+// userResourceId returns the resource ID of the user of the portal.
+func (s *portalSet) userResourceId(ctx context.Context, p *portal, userId string) (*v2.ResourceId, error) {
+	id, err := s.qualifiedId(ctx, p, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return getUserResourceId(id), nil
+}
+
+// parseQualifiedId returns the HubSpot ID and the account ID of a resource ID built by qualifiedId,
+// the account ID is empty for objects of the primary portal.
+func parseQualifiedId(resourceId string) (string, string) {
+	id, accountId, _ := strings.Cut(resourceId, ":")
+
+	return id, accountId
+}
+
+// forUser returns the portal of the user resource and the HubSpot ID of the user.
+func (s *portalSet) forUser(ctx context.Context, resourceId *v2.ResourceId) (*portal, string, error) {
+	userId, accountId := parseQualifiedId(resourceId.Resource)
+	if accountId == "" {
+		return s.primary(), userId, nil
+	}
+
+	p, err := s.forAccount(ctx, accountId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return p, userId, nil
+}
+
+// userOfPortal returns the HubSpot ID of the user resource, users of another portal than provided one are rejected.
+func (s *portalSet) userOfPortal(ctx context.Context, p *portal, resourceId *v2.ResourceId) (string, error) {
+	userPortal, userId, err := s.forUser(ctx, resourceId)
+	if err != nil {
+		return "", err
+	}
+	if userPortal != p {
+		return "", status.Errorf(codes.InvalidArgument, "hubspot-connector: user %s does not belong to the portal", resourceId.Resource)
+	}
+
+	return userId, nil
+}
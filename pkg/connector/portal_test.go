@@ -0,0 +1,247 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// childTypes returns the IDs of the resource types synced under the resource.
+func childTypes(t *testing.T, resource *v2.Resource) []string {
+	t.Helper()
+
+	var rv []string
+	for _, a := range resource.Annotations {
+		childType := &v2.ChildResourceType{}
+		if !a.MessageIs(childType) {
+			continue
+		}
+		if err := a.UnmarshalTo(childType); err != nil {
+			t.Fatal(err)
+		}
+		rv = append(rv, childType.ResourceTypeId)
+	}
+
+	return rv
+}
+
+// countRequests returns the number of requests the server received for the path.
+func countRequests(s *hubspottest.Server, path string) int {
+	count := 0
+	for _, request := range s.Requests() {
+		if request.Path == path {
+			count++
+		}
+	}
+
+	return count
+}
+
+// sandboxPortals returns a production portal with the other portal as one of its two sandboxes.
+func sandboxPortals(t *testing.T) (*hubspottest.Server, *hubspottest.Server, *portalSet) {
+	t.Helper()
+
+	production, sandbox, portals := twoPortals(t)
+	sandbox.SetAccount(hubspot.Account{Id: 67890, Type: hubspot.AccountTypeSandbox})
+	production.AddSandbox(hubspot.Sandbox{Id: 67890, Name: "Staging", Type: "STANDARD"})
+	production.AddSandbox(hubspot.Sandbox{Id: 55555, Name: "Development", Type: "DEVELOPMENT"})
+
+	return production, sandbox, portals
+}
+
+func TestAccountsListSandboxesUnderTheirProductionAccount(t *testing.T) {
+	production, sandbox, portals := sandboxPortals(t)
+	builder := accountBuilder(portals, false, true)
+
+	accounts := listAll(t, builder, nil)
+	if len(accounts) != 1 || accounts[0].Id.Resource != "12345" {
+		t.Fatalf("expected only the production account at the root, got %v", accounts)
+	}
+	if !slices.Contains(childTypes(t, accounts[0]), resourceTypeAccount.Id) {
+		t.Fatal("expected sandboxes to be synced under the production account")
+	}
+
+	sandboxes := listAll(t, builder, accounts[0].Id)
+	if len(sandboxes) != 2 {
+		t.Fatalf("expected 2 sandboxes, got %v", sandboxes)
+	}
+	for _, resource := range sandboxes {
+		children := childTypes(t, resource)
+		switch resource.Id.Resource {
+		case "67890":
+			if !slices.Contains(children, resourceTypeUser.Id) || slices.Contains(children, resourceTypeAccount.Id) {
+				t.Fatalf("expected the users of the sandbox with credentials to be synced, got %v", children)
+			}
+		case "55555":
+			if len(children) != 0 {
+				t.Fatalf("expected nothing synced under the sandbox without credentials, got %v", children)
+			}
+		default:
+			t.Fatalf("unexpected sandbox %s", resource.Id.Resource)
+		}
+	}
+
+	// the sandboxes are fetched once per sync, and never from the sandbox portal
+	if got := countRequests(production, "/"+hubspot.SandboxesBaseURL); got != 1 {
+		t.Fatalf("expected the sandboxes to be fetched once, got %d requests", got)
+	}
+	if got := countRequests(sandbox, "/"+hubspot.SandboxesBaseURL); got != 0 {
+		t.Fatalf("expected no sandboxes to be fetched from the sandbox, got %d requests", got)
+	}
+
+	// a new sync fetches them again
+	listAll(t, builder, nil)
+	if got := countRequests(production, "/"+hubspot.SandboxesBaseURL); got != 2 {
+		t.Fatalf("expected the sandboxes to be fetched again, got %d requests", got)
+	}
+}
+
+func TestAccountsSkipUnavailableSandboxes(t *testing.T) {
+	production, _, portals := sandboxPortals(t)
+	builder := accountBuilder(portals, false, true)
+
+	production.InjectFailure(hubspottest.Failure{
+		Path:       "/" + hubspot.SandboxesBaseURL,
+		StatusCode: http.StatusForbidden,
+		Category:   "MISSING_SCOPES",
+		Times:      2,
+	})
+
+	// without the sandboxes of the production account, the sandbox portal is listed on its own
+	accounts, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %v", accounts)
+	}
+
+	sandboxes, _, _, err := builder.List(context.Background(), accountId(12345), &pagination.Token{})
+	if err != nil || len(sandboxes) != 0 {
+		t.Fatalf("expected no sandboxes, got %v, %v", sandboxes, err)
+	}
+}
+
+func TestAccountsSurfaceSandboxErrors(t *testing.T) {
+	production, _, portals := sandboxPortals(t)
+	builder := accountBuilder(portals, false, true)
+
+	production.InjectFailure(hubspottest.Failure{
+		Path:       "/" + hubspot.SandboxesBaseURL,
+		StatusCode: http.StatusUnauthorized,
+		Category:   "INVALID_AUTHENTICATION",
+		Times:      2,
+	})
+
+	_, _, _, err := builder.List(context.Background(), nil, &pagination.Token{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	_, _, _, err = builder.List(context.Background(), accountId(12345), &pagination.Token{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestPortalRouting(t *testing.T) {
+	ctx := context.Background()
+	primary, other, portals := twoPortals(t)
+	primaryTeam := primary.AddTeam(hubspot.Team{Name: "Sales"})
+	other.AddTeam(hubspot.Team{Name: "Marketing"})
+	otherTeam := other.AddTeam(hubspot.Team{Name: "Support"})
+
+	tests := []struct {
+		name     string
+		parentId *v2.ResourceId
+		want     *portal
+		wantCode codes.Code
+	}{
+		{name: "no parent", want: portals.primary()},
+		{name: "primary account", parentId: accountId(12345), want: portals.primary()},
+		{name: "other account", parentId: accountId(67890), want: portals.portals[1]},
+		{name: "team of the primary portal", parentId: &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: primaryTeam.Id}, want: portals.primary()},
+		{name: "team of the other portal", parentId: &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: otherTeam.Id + ":67890"}, want: portals.portals[1]},
+		{name: "unknown team", parentId: &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: otherTeam.Id + ":99999"}, wantCode: codes.NotFound},
+		{name: "unknown account", parentId: accountId(99999), wantCode: codes.NotFound},
+		{name: "unexpected parent", parentId: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: "1"}, wantCode: codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		p, err := portals.forParent(ctx, tt.parentId)
+		if tt.want != nil {
+			if err != nil || p != tt.want {
+				t.Errorf("%s: expected portal %p, got %p, %v", tt.name, tt.want, p, err)
+			}
+			continue
+		}
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.wantCode, err)
+		}
+	}
+
+	// teams are routed through their resource ID, without listing the teams of the portals
+	for _, r := range append(primary.Requests(), other.Requests()...) {
+		if r.Path == "/settings/v3/users/teams" {
+			t.Fatalf("expected no team listing, got %s %s", r.Method, r.Path)
+		}
+	}
+
+	// sandboxes without credentials have no portal
+	p, err := portals.findAccount(ctx, "55555")
+	if err != nil || p != nil {
+		t.Fatalf("expected no portal, got %p, %v", p, err)
+	}
+}
+
+func TestTeamAndRoleIdsAreQualifiedOutsideThePrimaryPortal(t *testing.T) {
+	primary, other, portals := twoPortals(t)
+	primaryTeam := primary.AddTeam(hubspot.Team{Name: "Sales"})
+	otherTeam := other.AddTeam(hubspot.Team{Name: "Sales"})
+	primaryRole := primary.AddRole(hubspot.Role{Name: "Manager"})
+	otherRole := other.AddRole(hubspot.Role{Name: "Manager"})
+
+	// both portals assign the same IDs
+	if primaryTeam.Id != otherTeam.Id || primaryRole.Id != otherRole.Id {
+		t.Fatalf("expected colliding IDs, got teams %s and %s, roles %s and %s", primaryTeam.Id, otherTeam.Id, primaryRole.Id, otherRole.Id)
+	}
+
+	tests := []struct {
+		name    string
+		builder connectorbuilder.ResourceSyncer
+		id      string
+	}{
+		{name: "teams", builder: teamBuilder(portals, false), id: otherTeam.Id},
+		{name: "roles", builder: roleBuilder(portals), id: otherRole.Id},
+	}
+
+	for _, tt := range tests {
+		primaryIds := map[string]bool{}
+		for _, resource := range listAll(t, tt.builder, accountId(12345)) {
+			primaryIds[resource.Id.Resource] = true
+		}
+		if !primaryIds[tt.id] {
+			t.Errorf("%s: expected %s in the primary portal, got %v", tt.name, tt.id, primaryIds)
+		}
+
+		var otherIds []string
+		for _, resource := range listAll(t, tt.builder, accountId(67890)) {
+			if primaryIds[resource.Id.Resource] {
+				t.Errorf("%s: resource ID %s is used by both portals", tt.name, resource.Id.Resource)
+			}
+			otherIds = append(otherIds, resource.Id.Resource)
+		}
+		if !slices.Contains(otherIds, tt.id+":67890") {
+			t.Errorf("%s: expected %s:67890 in the other portal, got %v", tt.name, tt.id, otherIds)
+		}
+	}
+}
@@ -13,6 +13,8 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -22,15 +24,15 @@ const (
 
 type roleResourceType struct {
 	resourceType *v2.ResourceType
-	client       *hubspot.Client
+	portals      *portalSet
 }
 
 func (r *roleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return r.resourceType
 }
 
-// Create a new connector resource for an HubSpot user.
-func roleResource(role *hubspot.Role, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+// Create a new connector resource for an HubSpot role, resourceId being its ID qualified for the portal.
+func roleResource(role *hubspot.Role, resourceId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
 	displayName := titleCase(role.Name)
 	profile := map[string]interface{}{
 		"role_id":   role.Id,
@@ -44,7 +46,7 @@ func roleResource(role *hubspot.Role, parentResourceID *v2.ResourceId) (*v2.Reso
 	resource, err := rs.NewRoleResource(
 		displayName,
 		resourceTypeRole,
-		role.Id,
+		resourceId,
 		roleTraitOptions,
 		rs.WithParentResourceID(parentResourceID),
 	)
@@ -61,17 +63,30 @@ func (r *roleResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 		return nil, "", nil, nil
 	}
 
-	roles, annotations, _ := r.client.GetRoles(ctx)
+	p, err := r.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	roles, annotations, _ := p.client.GetRoles(ctx)
 	if roles == nil {
 		// do not list user entitlements when account does not support roles
 		return nil, "", annotations, nil
 	}
 
+	// add concrete super admin role
+	roles = append(roles, *hubspot.NewRole(superAdminRole, "Super Admin"))
+
 	var rv []*v2.Resource
 	for _, role := range roles {
 		roleCopy := role
 
-		rr, err := roleResource(&roleCopy, parentId)
+		resourceId, err := r.portals.qualifiedId(ctx, p, role.Id)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rr, err := roleResource(&roleCopy, resourceId, parentId)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -79,16 +94,45 @@ func (r *roleResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 		rv = append(rv, rr)
 	}
 
-	// add concrete super admin role
-	saRole := hubspot.NewRole(superAdminRole, "Super Admin")
-	sar, err := roleResource(saRole, parentId)
+	return rv, "", annotations, nil
+}
+
+// Get returns a single role, HubSpot does not expose a single role endpoint so it is looked up among all roles.
+func (r *roleResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	roleId, _ := parseQualifiedId(resourceId.Resource)
+	if roleId == superAdminRole {
+		rr, err := roleResource(hubspot.NewRole(roleId, "Super Admin"), resourceId.Resource, parentResourceId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rr, nil, nil
+	}
+
+	p, err := r.portals.forParent(ctx, parentResourceId)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, nil, err
 	}
 
-	rv = append(rv, sar)
+	roles, annotations, err := p.client.GetRoles(ctx)
+	if err != nil {
+		return nil, annotations, fmt.Errorf("hubspot-connector: failed to list roles: %w", err)
+	}
 
-	return rv, "", annotations, nil
+	for _, role := range roles {
+		if role.Id != roleId {
+			continue
+		}
+
+		rr, err := roleResource(&role, resourceId.Resource, parentResourceId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rr, annotations, nil
+	}
+
+	return nil, annotations, status.Errorf(codes.NotFound, "hubspot-connector: role %s not found", resourceId.Resource)
 }
 
 func (r *roleResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
@@ -116,15 +160,12 @@ func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, to
 		return nil, "", nil, err
 	}
 
-	users, nextToken, annotations, err := r.client.GetUsers(ctx, hubspot.GetUsersVars{
-		Limit: ResourcesPageSize,
-		After: bag.PageToken(),
-	})
+	p, err := r.portals.forResource(ctx, resource)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list users: %w", err)
+		return nil, "", nil, err
 	}
 
-	pageToken, err := bag.NextToken(nextToken)
+	users, annotations, err := p.users.Users(ctx)
 	if err != nil {
 		return nil, "", nil, err
 	}
@@ -140,7 +181,6 @@ func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, to
 		return nil, "", nil, fmt.Errorf("hubspot-connector: error parsing role id from role profile")
 	}
 
-	var rv []*v2.Grant
 	var filteredUsers []hubspot.User
 	if roleId == superAdminRole {
 		filteredUsers = filterUsersBySuperAdmin(users)
@@ -148,8 +188,17 @@ func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, to
 		filteredUsers = filterUsersByRole(roleId, users)
 	}
 
-	for _, user := range filteredUsers {
-		userResourceId := getUserResourceId(user.Id)
+	start, end, pageToken, err := offsetPage(bag, len(filteredUsers))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, user := range filteredUsers[start:end] {
+		userResourceId, err := r.portals.userResourceId(ctx, p, user.Id)
+		if err != nil {
+			return nil, "", nil, err
+		}
 		rv = append(rv, grant.NewGrant(
 			resource,
 			roleMembership,
@@ -173,20 +222,51 @@ func (r *roleResourceType) Grant(ctx context.Context, principal *v2.Resource, en
 		return nil, fmt.Errorf("hubspot-connector: only users can be granted role membership")
 	}
 
-	roleId := entitlement.Resource.Id.Resource
+	roleId, _ := parseQualifiedId(entitlement.Resource.Id.Resource)
 
-	// no need to check current user role - only rewriting is supported
-	// grant role membership
-	annos, err := r.client.UpdateUser(
-		ctx,
-		principal.Id.Resource,
-		&hubspot.UpdateUserPayload{
-			RoleId: roleId,
-		},
-	)
+	p, err := r.portals.forResource(ctx, entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := r.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// need to check principal teams - without specifying them, they will be removed
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	if roleId == superAdminRole {
+		if user.SuperAdmin {
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+
+		annos, err := setSuperAdmin(ctx, p.client, &user, true)
+		if err != nil {
+			return annos, err
+		}
+		p.users.Invalidate()
+
+		return annos, nil
+	}
+
+	if containsRole(user.RoleIDs, roleId) {
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	// only rewriting is supported, the previous role is replaced
+	payload := updateUserPayload(&user)
+	payload.RoleId = roleId
+
+	annos, err := p.client.UpdateUser(ctx, userId, payload)
 	if err != nil {
 		return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
 	}
+	p.users.Invalidate()
 
 	return annos, nil
 }
@@ -206,22 +286,94 @@ func (r *roleResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotat
 		return nil, fmt.Errorf("hubspot-connector: only users can have role membership revoked")
 	}
 
-	// revoke role membership
-	annos, err := r.client.UpdateUser(
-		ctx,
-		principal.Id.Resource,
-		&hubspot.UpdateUserPayload{},
-	)
+	roleId, _ := parseQualifiedId(grant.Entitlement.Resource.Id.Resource)
+
+	p, err := r.portals.forResource(ctx, grant.Entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := r.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	if roleId == superAdminRole {
+		if !user.SuperAdmin {
+			return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+		}
+
+		err = ensureOtherSuperAdmin(ctx, p.users, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		annos, err := setSuperAdmin(ctx, p.client, &user, false)
+		if err != nil {
+			return annos, err
+		}
+		p.users.Invalidate()
+
+		return annos, nil
+	}
+
+	if !containsRole(user.RoleIDs, roleId) {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	// revoke role membership, keeping the team assignments in place
+	payload := updateUserPayload(&user)
+	payload.ClearRole = true
+
+	annos, err := p.client.UpdateUser(ctx, userId, payload)
 	if err != nil {
 		return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
 	}
+	p.users.Invalidate()
+
+	return annos, nil
+}
+
+// setSuperAdmin grants or removes the super admin privileges of the user, keeping its role and teams.
+func setSuperAdmin(ctx context.Context, client *hubspot.Client, user *hubspot.User, superAdmin bool) (annotations.Annotations, error) {
+	payload := updateUserPayload(user)
+	payload.SuperAdmin = &superAdmin
+
+	annos, err := client.UpdateUser(ctx, user.Id, payload)
+	if err != nil {
+		return annos, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
+	}
 
 	return annos, nil
 }
 
-func roleBuilder(client *hubspot.Client) *roleResourceType {
+// ensureOtherSuperAdmin refuses to remove the super admin privileges of the user
+// when no other user of the account is a super admin.
+func ensureOtherSuperAdmin(ctx context.Context, users *userSnapshot, userId string) error {
+	// the snapshot may be as old as the last sync, look at the current super admins
+	users.Invalidate()
+	allUsers, _, err := users.Users(ctx)
+	if err != nil {
+		return fmt.Errorf("hubspot-connector: failed to list users: %w", err)
+	}
+
+	for _, user := range filterUsersBySuperAdmin(allUsers) {
+		if user.Id != userId {
+			return nil
+		}
+	}
+
+	return status.Error(codes.FailedPrecondition, "hubspot-connector: cannot revoke the last super admin of the account")
+}
+
+func roleBuilder(portals *portalSet) *roleResourceType {
 	return &roleResourceType{
 		resourceType: resourceTypeRole,
-		client:       client,
+		portals:      portals,
 	}
 }
@@ -0,0 +1,210 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func roleEntitlement(roleId string) *v2.Entitlement {
+	return &v2.Entitlement{
+		Resource: &v2.Resource{
+			Id:               &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: roleId},
+			ParentResourceId: accountId(hubspottest.DefaultPortalId),
+		},
+		Slug: roleMembership,
+	}
+}
+
+// updateRequests returns the number of user updates received by the server.
+func updateRequests(s *hubspottest.Server) int {
+	var n int
+	for _, request := range s.Requests() {
+		if request.Method == "PUT" {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestRoleRevokeKeepsTeams(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	builder := roleBuilder(newTestConnector(t, s).portals)
+
+	grant := &v2.Grant{Principal: userPrincipal(user.Id), Entitlement: roleEntitlement(user.RoleIDs[0])}
+	if _, err := builder.Revoke(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := s.User(user.Id)
+	if len(updated.RoleIDs) != 0 {
+		t.Fatalf("expected the role to be cleared, got %v", updated.RoleIDs)
+	}
+	if updated.TeamId != user.TeamId || !slices.Equal(updated.SecondaryTeamIDs, user.SecondaryTeamIDs) {
+		t.Fatalf("expected the teams to be kept, got %+v", updated)
+	}
+
+	// the role is already revoked
+	annos, err := builder.Revoke(ctx, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !annos.Contains(&v2.GrantAlreadyRevoked{}) {
+		t.Fatalf("expected GrantAlreadyRevoked, got %v", annos)
+	}
+	if n := updateRequests(s); n != 1 {
+		t.Fatalf("expected a single update, got %d", n)
+	}
+}
+
+func TestRoleGrantReplacesRole(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	manager := s.AddRole(hubspot.Role{Name: "Sales Manager"})
+	hs := newTestConnector(t, s)
+	builder := roleBuilder(hs.portals)
+
+	annos, err := builder.Grant(ctx, userPrincipal(user.Id), roleEntitlement(user.RoleIDs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !annos.Contains(&v2.GrantAlreadyExists{}) {
+		t.Fatalf("expected GrantAlreadyExists, got %v", annos)
+	}
+	if n := updateRequests(s); n != 0 {
+		t.Fatalf("expected no update, got %d", n)
+	}
+
+	// load the snapshot before the grant, as the sync does
+	roleResource, err := roleResource(&manager, manager.Id, accountId(hubspottest.DefaultPortalId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := grantsOf(t, builder, roleResource); len(got) != 0 {
+		t.Fatalf("expected no grant of the new role, got %v", got)
+	}
+
+	if _, err := builder.Grant(ctx, userPrincipal(user.Id), roleEntitlement(manager.Id)); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := s.User(user.Id)
+	if !slices.Equal(updated.RoleIDs, []string{manager.Id}) {
+		t.Fatalf("expected the role to be replaced, got %v", updated.RoleIDs)
+	}
+	if updated.TeamId != user.TeamId || !slices.Equal(updated.SecondaryTeamIDs, user.SecondaryTeamIDs) {
+		t.Fatalf("expected the teams to be kept, got %+v", updated)
+	}
+
+	// the grants are computed from the users reloaded after the grant
+	want := []string{"role:" + manager.Id + ":member/" + user.Id}
+	if got := grantsOf(t, builder, roleResource); !slices.Equal(got, want) {
+		t.Fatalf("got grants %v, want %v", got, want)
+	}
+}
+
+func TestRoleGet(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	builder := roleBuilder(newTestConnector(t, s).portals)
+
+	role, _, err := builder.Get(ctx, &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: user.RoleIDs[0]}, accountId(hubspottest.DefaultPortalId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.Id.Resource != user.RoleIDs[0] || role.DisplayName != "Sales Rep" {
+		t.Fatalf("expected role %s Sales Rep, got %s %s", user.RoleIDs[0], role.Id.Resource, role.DisplayName)
+	}
+
+	// the super admin role is not a HubSpot role, it is resolved without a request
+	requests := len(s.Requests())
+	role, _, err = builder.Get(ctx, &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: superAdminRole}, accountId(hubspottest.DefaultPortalId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role.Id.Resource != superAdminRole || len(s.Requests()) != requests {
+		t.Fatalf("expected the super admin role without a request, got %s", role.Id.Resource)
+	}
+
+	_, _, err = builder.Get(ctx, &v2.ResourceId{ResourceType: resourceTypeRole.Id, Resource: "404"}, accountId(hubspottest.DefaultPortalId))
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestRoleSuperAdminGrantAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	builder := roleBuilder(newTestConnector(t, s).portals)
+
+	superAdmin, err := roleResource(hubspot.NewRole(superAdminRole, "Super Admin"), superAdminRole, accountId(hubspottest.DefaultPortalId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entitlement := roleEntitlement(superAdminRole)
+	grant := &v2.Grant{Principal: userPrincipal(user.Id), Entitlement: entitlement}
+
+	// load the snapshot before the grant, as the sync does
+	if got := grantsOf(t, builder, superAdmin); len(got) != 0 {
+		t.Fatalf("expected no super admin, got %v", got)
+	}
+
+	if _, err := builder.Grant(ctx, userPrincipal(user.Id), entitlement); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := s.User(user.Id)
+	if !updated.SuperAdmin || !slices.Equal(updated.RoleIDs, user.RoleIDs) || updated.TeamId != user.TeamId {
+		t.Fatalf("expected a super admin keeping its role and teams, got %+v", updated)
+	}
+
+	annos, err := builder.Grant(ctx, userPrincipal(user.Id), entitlement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !annos.Contains(&v2.GrantAlreadyExists{}) {
+		t.Fatalf("expected GrantAlreadyExists, got %v", annos)
+	}
+
+	// the grants are computed from the users reloaded after the grant
+	want := []string{"role:" + superAdminRole + ":member/" + user.Id}
+	if got := grantsOf(t, builder, superAdmin); !slices.Equal(got, want) {
+		t.Fatalf("got grants %v, want %v", got, want)
+	}
+
+	// the last super admin of the account is kept
+	if _, err := builder.Revoke(ctx, grant); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	if updated, _ := s.User(user.Id); !updated.SuperAdmin {
+		t.Fatal("expected the last super admin to be kept")
+	}
+
+	admin := s.AddUser(hubspot.User{Email: "admin@example.com", SuperAdmin: true})
+	if _, err := builder.Revoke(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := s.User(user.Id); updated.SuperAdmin {
+		t.Fatal("expected the super admin privileges to be revoked")
+	}
+
+	annos, err = builder.Revoke(ctx, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !annos.Contains(&v2.GrantAlreadyRevoked{}) {
+		t.Fatalf("expected GrantAlreadyRevoked, got %v", annos)
+	}
+
+	want = []string{"role:" + superAdminRole + ":member/" + admin.Id}
+	if got := grantsOf(t, builder, superAdmin); !slices.Equal(got, want) {
+		t.Fatalf("got grants %v, want %v", got, want)
+	}
+}
@@ -14,6 +14,8 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -21,17 +23,23 @@ const (
 	secondaryMemberEntitlement = "secondary-member"
 )
 
+type teamMember struct {
+	principal   *v2.ResourceId
+	entitlement string
+}
+
 type teamResourceType struct {
-	resourceType *v2.ResourceType
-	client       *hubspot.Client
+	resourceType     *v2.ResourceType
+	portals          *portalSet
+	expandChildTeams bool
 }
 
 func (t *teamResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return t.resourceType
 }
 
-// Create a new connector resource for an HubSpot Team.
-func teamResource(team *hubspot.Team, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
+// Create a new connector resource for an HubSpot Team, resourceId being its ID qualified for the portal.
+func teamResource(team *hubspot.Team, resourceId string, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
 	profile := map[string]interface{}{
 		"team_id":   team.Id,
 		"team_name": team.Name,
@@ -45,12 +53,28 @@ func teamResource(team *hubspot.Team, parentResourceID *v2.ResourceId) (*v2.Reso
 		profile["team_secondary_users"] = strings.Join(team.SecondaryUserIDs, ",")
 	}
 
+	resourceOptions := []rs.ResourceOption{
+		rs.WithParentResourceID(parentResourceID),
+	}
+
+	if len(team.ChildTeams) > 0 {
+		childTeamIDs := make([]string, 0, len(team.ChildTeams))
+		for _, child := range team.ChildTeams {
+			childTeamIDs = append(childTeamIDs, child.Id)
+		}
+		profile["team_child_teams"] = strings.Join(childTeamIDs, ",")
+
+		resourceOptions = append(resourceOptions, rs.WithAnnotation(
+			&v2.ChildResourceType{ResourceTypeId: resourceTypeTeam.Id},
+		))
+	}
+
 	resource, err := rs.NewGroupResource(
 		team.Name,
 		resourceTypeTeam,
-		team.Id,
+		resourceId,
 		[]rs.GroupTraitOption{rs.WithGroupProfile(profile)},
-		rs.WithParentResourceID(parentResourceID),
+		resourceOptions...,
 	)
 
 	if err != nil {
@@ -65,16 +89,36 @@ func (t *teamResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 		return nil, "", nil, nil
 	}
 
-	teams, annotations, err := t.client.GetTeams(ctx)
+	p, err := t.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	teams, annotations, err := p.client.GetTeams(ctx)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("hubspot-connector: failed to list teams: %w", err)
 	}
 
+	// top level teams are listed under the account, child teams under their parent team
+	index, teamIDs := teamHierarchy(teams)
+	if parentId.ResourceType == resourceTypeTeam.Id {
+		parentTeamId, _ := parseQualifiedId(parentId.Resource)
+		teamIDs = nil
+		for _, child := range index[parentTeamId].ChildTeams {
+			teamIDs = append(teamIDs, child.Id)
+		}
+	}
+
 	var rv []*v2.Resource
-	for _, team := range teams {
-		teamCopy := team
+	for _, id := range teamIDs {
+		teamCopy := index[id]
 
-		tResource, err := teamResource(&teamCopy, parentId)
+		resourceId, err := t.portals.qualifiedId(ctx, p, id)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		tResource, err := teamResource(&teamCopy, resourceId, parentId)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -85,15 +129,47 @@ func (t *teamResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 	return rv, "", annotations, nil
 }
 
+// Get returns a single team, HubSpot does not expose a single team endpoint so it is looked up among all teams.
+func (t *teamResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	p, err := t.portals.forParent(ctx, parentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teams, annotations, err := p.client.GetTeams(ctx)
+	if err != nil {
+		return nil, annotations, fmt.Errorf("hubspot-connector: failed to list teams: %w", err)
+	}
+
+	teamId, _ := parseQualifiedId(resourceId.Resource)
+	index, _ := teamHierarchy(teams)
+	team, ok := index[teamId]
+	if !ok {
+		return nil, annotations, status.Errorf(codes.NotFound, "hubspot-connector: team %s not found", resourceId.Resource)
+	}
+
+	tResource, err := teamResource(&team, resourceId.Resource, parentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tResource, annotations, nil
+}
+
 func (t *teamResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
+	grantableTo := []*v2.ResourceType{resourceTypeUser}
+	if t.expandChildTeams {
+		grantableTo = append(grantableTo, resourceTypeTeam)
+	}
+
 	primaryAssignmentOptions := []ent.EntitlementOption{
-		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithGrantableTo(grantableTo...),
 		ent.WithDisplayName(fmt.Sprintf("%s Team primary member", resource.DisplayName)),
 		ent.WithDescription(fmt.Sprintf("Access to %s team in HubSpot", resource.DisplayName)),
 	}
 	secondaryAssignmentOptions := []ent.EntitlementOption{
-		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithGrantableTo(grantableTo...),
 		ent.WithDisplayName(fmt.Sprintf("%s Team secondary member", resource.DisplayName)),
 		ent.WithDescription(fmt.Sprintf("Access to %s team in HubSpot", resource.DisplayName)),
 	}
@@ -116,60 +192,105 @@ func (t *teamResourceType) Entitlements(ctx context.Context, resource *v2.Resour
 	return rv, "", nil, nil
 }
 
-func (t *teamResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+func (t *teamResourceType) Grants(ctx context.Context, resource *v2.Resource, token *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := parsePageToken(token.Token, &v2.ResourceId{ResourceType: resourceTypeUser.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	teamTrait, err := rs.GetGroupTrait(resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	var primaryUserIDs, secondaryUserIDs []string
+	p, err := t.portals.forResource(ctx, resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// membership is stored on the team profile, so users don't need to be fetched
+	var members []teamMember
 
 	primaryUserIDsString, ok := rs.GetProfileStringValue(teamTrait.Profile, "team_primary_users")
-	if ok {
-		primaryUserIDs = strings.Split(primaryUserIDsString, ",")
+	if ok && primaryUserIDsString != "" {
+		for _, id := range strings.Split(primaryUserIDsString, ",") {
+			members = append(members, teamMember{principal: getUserResourceId(id), entitlement: primaryMemberEntitlement})
+		}
 	}
 
 	secondaryUserIDsString, ok := rs.GetProfileStringValue(teamTrait.Profile, "team_secondary_users")
-	if ok {
-		secondaryUserIDs = strings.Split(secondaryUserIDsString, ",")
+	if ok && secondaryUserIDsString != "" {
+		for _, id := range strings.Split(secondaryUserIDsString, ",") {
+			members = append(members, teamMember{principal: getUserResourceId(id), entitlement: secondaryMemberEntitlement})
+		}
+	}
+
+	// members of child teams inherit the membership of the parent team
+	childTeamIDsString, ok := rs.GetProfileStringValue(teamTrait.Profile, "team_child_teams")
+	if t.expandChildTeams && ok && childTeamIDsString != "" {
+		for _, id := range strings.Split(childTeamIDsString, ",") {
+			childResourceId, err := t.portals.qualifiedId(ctx, p, id)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			childTeamId := &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: childResourceId}
+			members = append(
+				members,
+				teamMember{principal: childTeamId, entitlement: primaryMemberEntitlement},
+				teamMember{principal: childTeamId, entitlement: secondaryMemberEntitlement},
+			)
+		}
+	}
+
+	start, end, pageToken, err := offsetPage(bag, len(members))
+	if err != nil {
+		return nil, "", nil, err
 	}
 
 	// create membership grants
 	var rv []*v2.Grant
-	for _, id := range primaryUserIDs {
-		user, _, err := t.client.GetUser(ctx, id)
+	for _, member := range members[start:end] {
+		if member.principal.ResourceType == resourceTypeTeam.Id {
+			childTeam := &v2.Resource{Id: member.principal}
+			rv = append(
+				rv,
+				grant.NewGrant(
+					resource,
+					member.entitlement,
+					member.principal,
+					grant.WithAnnotation(&v2.GrantExpandable{
+						EntitlementIds: []string{ent.NewEntitlementID(childTeam, member.entitlement)},
+					}),
+				),
+			)
+			continue
+		}
+
+		// skip stale membership of users no longer present in the account
+		exists, err := p.users.Contains(ctx, member.principal.Resource)
 		if err != nil {
 			return nil, "", nil, err
 		}
+		if !exists {
+			continue
+		}
 
-		userResourceId := getUserResourceId(user.Id)
-		rv = append(
-			rv,
-			grant.NewGrant(
-				resource,
-				primaryMemberEntitlement,
-				userResourceId,
-			),
-		)
-	}
-
-	for _, id := range secondaryUserIDs {
-		user, _, err := t.client.GetUser(ctx, id)
+		userResourceId, err := t.portals.userResourceId(ctx, p, member.principal.Resource)
 		if err != nil {
 			return nil, "", nil, err
 		}
-		userResourceId := getUserResourceId(user.Id)
+
 		rv = append(
 			rv,
 			grant.NewGrant(
 				resource,
-				secondaryMemberEntitlement,
+				member.entitlement,
 				userResourceId,
 			),
 		)
 	}
 
-	return rv, "", nil, nil
+	return rv, pageToken, nil, nil
 }
 
 func (t *teamResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
@@ -185,55 +306,46 @@ func (t *teamResourceType) Grant(ctx context.Context, principal *v2.Resource, en
 		return nil, fmt.Errorf("hubspot-connector: only users can be granted team membership")
 	}
 
-	teamId := entitlement.Resource.Id.Resource
+	teamId, _ := parseQualifiedId(entitlement.Resource.Id.Resource)
 	entitlementId := entitlement.Slug
 
-	// need to check principal role - without specifying role, it will be removed
-	user, _, err := t.client.GetUser(ctx, principal.Id.Resource)
+	p, err := t.portals.forResource(ctx, entitlement.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+		return nil, err
+	}
+
+	userId, err := t.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
 	}
 
-	// there is only one role supported so far
-	var roleId string
-	if len(user.RoleIDs) != 0 {
-		roleId = user.RoleIDs[0]
+	// the role and the other team of the user are sent along, so that they are kept
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
 	}
 
-	var annos annotations.Annotations
+	payload := updateUserPayload(&user)
 	switch entitlementId {
 	case primaryMemberEntitlement:
 		if user.TeamId == teamId {
 			return nil, fmt.Errorf("hubspot-connector: user is already a primary member of team %s", teamId)
 		}
 
-		annos, err = t.client.UpdateUser(
-			ctx,
-			principal.Id.Resource,
-			&hubspot.UpdateUserPayload{
-				RoleId:        roleId,
-				PrimaryTeamId: teamId,
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
-		}
+		payload.PrimaryTeamId = teamId
 	case secondaryMemberEntitlement:
 		if containsTeam(user.SecondaryTeamIDs, teamId) {
 			return nil, fmt.Errorf("hubspot-connector: user is already a secondary member of team %s", teamId)
 		}
 
-		annos, err = t.client.UpdateUser(
-			ctx,
-			principal.Id.Resource,
-			&hubspot.UpdateUserPayload{
-				RoleId:           roleId,
-				SecondaryTeamIDs: append(user.SecondaryTeamIDs, teamId),
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
-		}
+		payload.SecondaryTeamIDs = append(payload.SecondaryTeamIDs, teamId)
+	default:
+		return nil, fmt.Errorf("hubspot-connector: unknown team entitlement %s", entitlementId)
+	}
+
+	annos, err := p.client.UpdateUser(ctx, userId, payload)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
 	}
 
 	return annos, nil
@@ -255,61 +367,54 @@ func (t *teamResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotat
 		return nil, fmt.Errorf("hubspot-connector: only users can have team membership revoked")
 	}
 
-	teamId := entitlement.Resource.Id.Resource
+	teamId, _ := parseQualifiedId(entitlement.Resource.Id.Resource)
 	entitlementId := entitlement.Slug
 
-	user, _, err := t.client.GetUser(ctx, principal.Id.Resource)
+	p, err := t.portals.forResource(ctx, entitlement.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+		return nil, err
 	}
 
-	var roleId string
-	if len(user.RoleIDs) != 0 {
-		roleId = user.RoleIDs[0]
+	userId, err := t.portals.userOfPortal(ctx, p, principal.Id)
+	if err != nil {
+		return nil, err
 	}
 
-	var annos annotations.Annotations
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	payload := updateUserPayload(&user)
 	switch entitlementId {
 	case primaryMemberEntitlement:
 		if user.TeamId != teamId {
 			return nil, fmt.Errorf("hubspot-connector: user is not a primary member of team %s", teamId)
 		}
 
-		annos, err = t.client.UpdateUser(
-			ctx,
-			principal.Id.Resource,
-			&hubspot.UpdateUserPayload{
-				RoleId: roleId,
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
-		}
+		payload.ClearPrimaryTeam = true
 	case secondaryMemberEntitlement:
 		if !containsTeam(user.SecondaryTeamIDs, teamId) {
 			return nil, fmt.Errorf("hubspot-connector: user is not a secondary member of team %s", teamId)
 		}
 
-		updatedTeams := removeTeam(user.SecondaryTeamIDs, teamId)
-		annos, err = t.client.UpdateUser(
-			ctx,
-			principal.Id.Resource,
-			&hubspot.UpdateUserPayload{
-				RoleId:           roleId,
-				SecondaryTeamIDs: updatedTeams,
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("hubspot-connector: failed to updated user: %w", err)
-		}
+		payload.SecondaryTeamIDs = removeTeam(user.SecondaryTeamIDs, teamId)
+	default:
+		return nil, fmt.Errorf("hubspot-connector: unknown team entitlement %s", entitlementId)
+	}
+
+	annos, err := p.client.UpdateUser(ctx, userId, payload)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
 	}
 
 	return annos, nil
 }
 
-func teamBuilder(client *hubspot.Client) *teamResourceType {
+func teamBuilder(portals *portalSet, expandChildTeams bool) *teamResourceType {
 	return &teamResourceType{
-		resourceType: resourceTypeTeam,
-		client:       client,
+		resourceType:     resourceTypeTeam,
+		portals:          portals,
+		expandChildTeams: expandChildTeams,
 	}
 }
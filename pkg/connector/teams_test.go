@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func userPrincipal(userId string) *v2.Resource {
+	return &v2.Resource{Id: &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: userId}}
+}
+
+func teamEntitlement(teamId string, slug string) *v2.Entitlement {
+	return &v2.Entitlement{
+		Resource: &v2.Resource{
+			Id:               &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: teamId},
+			ParentResourceId: accountId(hubspottest.DefaultPortalId),
+		},
+		Slug: slug,
+	}
+}
+
+// teamMembershipServer returns a server with a user having a role, a primary team and a secondary team.
+func teamMembershipServer(t *testing.T) (*hubspottest.Server, hubspot.User, []hubspot.Team) {
+	t.Helper()
+
+	s := hubspottest.NewServer()
+	t.Cleanup(s.Close)
+
+	var teams []hubspot.Team
+	for _, name := range []string{"Sales", "Marketing", "Support"} {
+		teams = append(teams, s.AddTeam(hubspot.Team{Name: name}))
+	}
+	role := s.AddRole(hubspot.Role{Name: "Sales Rep"})
+	user := s.AddUser(hubspot.User{
+		Email:            "jane@example.com",
+		RoleIDs:          []string{role.Id},
+		TeamId:           teams[0].Id,
+		SecondaryTeamIDs: []string{teams[1].Id},
+	})
+
+	return s, user, teams
+}
+
+func TestTeamGrantKeepsOtherAssignments(t *testing.T) {
+	ctx := context.Background()
+	s, user, teams := teamMembershipServer(t)
+	builder := teamBuilder(newTestConnector(t, s).portals, false)
+
+	if _, err := builder.Grant(ctx, userPrincipal(user.Id), teamEntitlement(teams[2].Id, secondaryMemberEntitlement)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.Grant(ctx, userPrincipal(user.Id), teamEntitlement(teams[2].Id, primaryMemberEntitlement)); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := s.User(user.Id)
+	if updated.TeamId != teams[2].Id {
+		t.Fatalf("expected primary team %s, got %s", teams[2].Id, updated.TeamId)
+	}
+	if want := []string{teams[1].Id, teams[2].Id}; !slices.Equal(updated.SecondaryTeamIDs, want) {
+		t.Fatalf("expected secondary teams %v, got %v", want, updated.SecondaryTeamIDs)
+	}
+	if !slices.Equal(updated.RoleIDs, user.RoleIDs) {
+		t.Fatalf("expected the role to be kept, got %v", updated.RoleIDs)
+	}
+}
+
+func TestTeamRevokePrimaryMembership(t *testing.T) {
+	ctx := context.Background()
+	s, user, teams := teamMembershipServer(t)
+	builder := teamBuilder(newTestConnector(t, s).portals, false)
+
+	grant := &v2.Grant{Principal: userPrincipal(user.Id), Entitlement: teamEntitlement(teams[0].Id, primaryMemberEntitlement)}
+	if _, err := builder.Revoke(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := s.User(user.Id)
+	if updated.TeamId != "" {
+		t.Fatalf("expected the primary team to be cleared, got %s", updated.TeamId)
+	}
+	if !slices.Equal(updated.SecondaryTeamIDs, user.SecondaryTeamIDs) || !slices.Equal(updated.RoleIDs, user.RoleIDs) {
+		t.Fatalf("expected the role and secondary teams to be kept, got %+v", updated)
+	}
+}
+
+func TestTeamRevokeLastSecondaryMembership(t *testing.T) {
+	ctx := context.Background()
+	s, user, teams := teamMembershipServer(t)
+	builder := teamBuilder(newTestConnector(t, s).portals, false)
+
+	grant := &v2.Grant{Principal: userPrincipal(user.Id), Entitlement: teamEntitlement(teams[1].Id, secondaryMemberEntitlement)}
+	if _, err := builder.Revoke(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := s.User(user.Id)
+	if len(updated.SecondaryTeamIDs) != 0 {
+		t.Fatalf("expected no secondary team, got %v", updated.SecondaryTeamIDs)
+	}
+	if updated.TeamId != user.TeamId || !slices.Equal(updated.RoleIDs, user.RoleIDs) {
+		t.Fatalf("expected the role and primary team to be kept, got %+v", updated)
+	}
+
+	if _, err := builder.Revoke(ctx, grant); err == nil {
+		t.Fatal("expected revoking a missing membership to fail")
+	}
+}
+
+func TestTeamGet(t *testing.T) {
+	ctx := context.Background()
+	s, _, teams := teamMembershipServer(t)
+	builder := teamBuilder(newTestConnector(t, s).portals, false)
+
+	team, _, err := builder.Get(ctx, &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: teams[1].Id}, accountId(hubspottest.DefaultPortalId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Id.Resource != teams[1].Id || team.DisplayName != "Marketing" {
+		t.Fatalf("expected team %s Marketing, got %s %s", teams[1].Id, team.Id.Resource, team.DisplayName)
+	}
+
+	_, _, err = builder.Get(ctx, &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: "404"}, accountId(hubspottest.DefaultPortalId))
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
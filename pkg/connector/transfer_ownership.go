@@ -0,0 +1,274 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const actionTransferOwnership = "transfer_ownership"
+
+// crmSearchResultLimit is the number of results the CRM search API returns for a single query,
+// records past the limit are picked up by searching again once the first ones are transferred.
+const crmSearchResultLimit = 10000
+
+// transferPassLimit bounds the searches for the records left with the source owner of an object type.
+const transferPassLimit = 50
+
+// transferPassDelay is the wait for the search index to catch up when a search only returned
+// records already transferred.
+var transferPassDelay = 2 * time.Second
+
+// ownedObjectTypes are the CRM object types that have a record owner.
+var ownedObjectTypes = []string{"deals", "contacts", "companies", "tickets"}
+
+func transferOwnershipSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionTransferOwnership,
+		DisplayName: "Transfer CRM record ownership",
+		Description: "Reassigns the CRM records owned by a user to another user.",
+		Arguments: []*config.Field{
+			stringArgField("source_user_id", "Source user ID", "ID of the HubSpot user currently owning the records.", true),
+			stringArgField("target_user_id", "Target user ID", "ID of the HubSpot user the records are transferred to.", true),
+			{
+				Name:        "object_types",
+				DisplayName: "Object types",
+				Description: "CRM object types to transfer, all of deals, contacts, companies and tickets by default.",
+				Field: &config.Field_StringSliceField{
+					StringSliceField: &config.StringSliceField{
+						DefaultValue: ownedObjectTypes,
+					},
+				},
+			},
+		},
+		ReturnTypes: []*config.Field{
+			stringArgField("source_owner_id", "Source owner ID", "", false),
+			stringArgField("target_owner_id", "Target owner ID", "", false),
+			intReturnField("total", "Transferred records"),
+			intReturnField("failed_total", "Records that failed to transfer"),
+			intReturnField("remaining_total", "Records left with the source user"),
+		},
+	}
+}
+
+// transferOwnership moves the deals, contacts, companies and tickets owned by the source user
+// to the target user. The number of records transferred so far is reported as the action progress,
+// the IDs of the records HubSpot failed to update and the number of records the search still returned
+// after the last pass are listed in the result by object type.
+func (hs *HubSpot) transferOwnership(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	sourceUserId, err := stringArg(args, "source_user_id", true)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetUserId, err := stringArg(args, "target_user_id", true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sourceUserId == targetUserId {
+		return nil, nil, status.Error(codes.InvalidArgument, "hubspot-connector: source and target users must be different")
+	}
+
+	objectTypes, err := stringSliceArg(args, "object_types")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(objectTypes) == 0 {
+		objectTypes = ownedObjectTypes
+	}
+	for _, objectType := range objectTypes {
+		if !slices.Contains(ownedObjectTypes, objectType) {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "hubspot-connector: unsupported object type %s", objectType)
+		}
+	}
+
+	source, _, err := hs.client.GetOwnerByUserId(ctx, sourceUserId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubspot-connector: failed to get owner of source user: %w", err)
+	}
+	target, _, err := hs.client.GetOwnerByUserId(ctx, targetUserId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubspot-connector: failed to get owner of target user: %w", err)
+	}
+	if target.Archived {
+		return nil, nil, status.Error(codes.FailedPrecondition, "hubspot-connector: target user is not an active owner")
+	}
+
+	progress := actionProgressFromContext(ctx)
+	transferred := make(map[string]interface{}, len(objectTypes))
+	failed := make(map[string]interface{})
+	remaining := make(map[string]interface{})
+	total, failedTotal, remainingTotal := 0, 0, 0
+	var annos annotations.Annotations
+	for _, objectType := range objectTypes {
+		progress.set("object_type", objectType)
+
+		count, failedIds, left, objectAnnos, err := hs.transferObjects(ctx, objectType, source.Id, target.Id, func(count int) {
+			transferred[objectType] = count
+			progress.set("transferred", maps.Clone(transferred))
+			progress.set("total", total+count)
+		})
+		annos = objectAnnos
+		if err != nil {
+			return nil, annos, fmt.Errorf("hubspot-connector: failed to transfer %s: %w", objectType, err)
+		}
+		transferred[objectType] = count
+		total += count
+
+		if len(failedIds) > 0 {
+			ids := make([]interface{}, 0, len(failedIds))
+			for _, id := range failedIds {
+				ids = append(ids, id)
+			}
+			failed[objectType] = ids
+			failedTotal += len(failedIds)
+		}
+		if left > 0 {
+			remaining[objectType] = left
+			remainingTotal += left
+		}
+	}
+
+	l := ctxzap.Extract(ctx)
+	if failedTotal > 0 {
+		l.Warn(
+			"hubspot-connector: some CRM records failed to transfer",
+			zap.String("source_owner_id", source.Id),
+			zap.String("target_owner_id", target.Id),
+			zap.Int("failed_total", failedTotal),
+		)
+	}
+	if remainingTotal > 0 {
+		l.Warn(
+			"hubspot-connector: some CRM records are still owned by the source user",
+			zap.String("source_owner_id", source.Id),
+			zap.Int("remaining_total", remainingTotal),
+		)
+	}
+
+	l.Info(
+		"hubspot-connector: transferred CRM records",
+		zap.String("source_owner_id", source.Id),
+		zap.String("target_owner_id", target.Id),
+		zap.Int("total", total),
+	)
+
+	rv, err := structpb.NewStruct(map[string]interface{}{
+		"source_owner_id": source.Id,
+		"target_owner_id": target.Id,
+		"transferred":     transferred,
+		"total":           total,
+		"failed":          failed,
+		"failed_total":    failedTotal,
+		"remaining":       remaining,
+		"remaining_total": remainingTotal,
+	})
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return rv, annos, nil
+}
+
+// transferObjects reassigns all records of the object type from one owner to another, calling
+// onProgress with the running count after every batch. It returns the IDs of the records that
+// failed to update, they are not retried, and the number of records left with the source owner
+// when the search still returned some after transferPassLimit passes.
+func (hs *HubSpot) transferObjects(
+	ctx context.Context,
+	objectType string,
+	sourceOwnerId string,
+	targetOwnerId string,
+	onProgress func(count int),
+) (int, []string, int, annotations.Annotations, error) {
+	var annos annotations.Annotations
+	// The search index is updated asynchronously, transferred records may be returned again.
+	transferred := make(map[string]bool)
+	failed := make(map[string]bool)
+	var failedIds []string
+	for pass := 0; ; pass++ {
+		var ids []string
+		total := 0
+		after := ""
+		for seen := 0; seen < crmSearchResultLimit; {
+			objects, searchTotal, nextToken, searchAnnos, err := hs.client.SearchObjectsByOwner(
+				ctx,
+				objectType,
+				sourceOwnerId,
+				hubspot.GetUsersVars{Limit: hubspot.MaxBatchSize, After: after},
+			)
+			annos = searchAnnos
+			if err != nil {
+				return len(transferred), failedIds, 0, annos, err
+			}
+
+			total = searchTotal
+			seen += len(objects)
+			for _, object := range objects {
+				if !transferred[object.Id] && !failed[object.Id] {
+					ids = append(ids, object.Id)
+				}
+			}
+
+			if nextToken == "" {
+				break
+			}
+			after = nextToken
+		}
+
+		// the records that failed to update stay with the source owner
+		remaining := max(total-len(failed), 0)
+		if remaining == 0 || pass == transferPassLimit {
+			return len(transferred), failedIds, remaining, annos, nil
+		}
+
+		// Every record returned was already handled, either the index has not caught up with
+		// the transferred records yet, or the records left are past the search result limit.
+		if len(ids) == 0 {
+			select {
+			case <-ctx.Done():
+				return len(transferred), failedIds, remaining, annos, ctx.Err()
+			case <-time.After(transferPassDelay):
+			}
+			continue
+		}
+
+		for batch := range slices.Chunk(ids, hubspot.MaxBatchSize) {
+			updateAnnos, err := hs.client.UpdateObjectsOwner(ctx, objectType, batch, targetOwnerId)
+			annos = updateAnnos
+
+			batchFailed := make(map[string]bool)
+			var batchErr *hubspot.BatchError
+			if errors.As(err, &batchErr) && len(batchErr.FailedIds()) > 0 {
+				for _, id := range batchErr.FailedIds() {
+					batchFailed[id] = true
+					if !failed[id] {
+						failed[id] = true
+						failedIds = append(failedIds, id)
+					}
+				}
+			} else if err != nil {
+				return len(transferred), failedIds, 0, annos, err
+			}
+
+			for _, id := range batch {
+				if !batchFailed[id] {
+					transferred[id] = true
+				}
+			}
+			onProgress(len(transferred))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func transferOwnershipArgs(t *testing.T, objectTypes ...interface{}) *structpb.Struct {
+	t.Helper()
+
+	args, err := structpb.NewStruct(map[string]interface{}{
+		"source_user_id": "1",
+		"target_user_id": "2",
+		"object_types":   objectTypes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return args
+}
+
+func ownershipServer(t *testing.T) *hubspottest.Server {
+	t.Helper()
+
+	s := hubspottest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddOwner(hubspot.Owner{BaseResource: hubspot.BaseResource{Id: "101"}, Email: "source@example.com", UserId: 1})
+	s.AddOwner(hubspot.Owner{BaseResource: hubspot.BaseResource{Id: "102"}, Email: "target@example.com", UserId: 2})
+
+	return s
+}
+
+func TestTransferOwnership(t *testing.T) {
+	s := ownershipServer(t)
+	for range 3 {
+		s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	}
+	s.AddObject("contacts", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	s.AddObject("contacts", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "103"}})
+
+	hs := newTestConnector(t, s)
+	rv, _, err := hs.transferOwnership(context.Background(), transferOwnershipArgs(t, "deals", "contacts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := rv.AsMap()
+	if result["total"] != float64(4) || result["failed_total"] != float64(0) {
+		t.Fatalf("expected 4 records transferred without failures, got %v", result)
+	}
+
+	for _, objectType := range []string{"deals", "contacts"} {
+		for _, object := range s.Objects(objectType) {
+			if object.Properties[hubspot.HSOwnerId] == "101" {
+				t.Fatalf("expected %s %s to be transferred", objectType, object.Id)
+			}
+		}
+	}
+}
+
+func TestTransferOwnershipReportsFailedRecords(t *testing.T) {
+	s := ownershipServer(t)
+	s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	locked := s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	s.LockObjects("deals", locked.Id)
+
+	hs := newTestConnector(t, s)
+	rv, _, err := hs.transferOwnership(context.Background(), transferOwnershipArgs(t, "deals"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := rv.AsMap()
+	if result["total"] != float64(1) || result["failed_total"] != float64(1) {
+		t.Fatalf("expected 1 record transferred and 1 failed, got %v", result)
+	}
+
+	failed, _ := result["failed"].(map[string]interface{})
+	deals, _ := failed["deals"].([]interface{})
+	if len(deals) != 1 || deals[0] != locked.Id {
+		t.Fatalf("expected deal %s to be reported as failed, got %v", locked.Id, failed)
+	}
+}
+
+// noTransferPassDelay makes the transfers search again without waiting for the search index.
+func noTransferPassDelay(t *testing.T) {
+	t.Helper()
+
+	delay := transferPassDelay
+	transferPassDelay = 0
+	t.Cleanup(func() { transferPassDelay = delay })
+}
+
+func TestTransferOwnershipWaitsForTheSearchIndex(t *testing.T) {
+	noTransferPassDelay(t)
+	s := ownershipServer(t)
+	for range 3 {
+		s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	}
+	// the transferred deals are returned by the next searches
+	s.SetSearchLag(5)
+
+	hs := newTestConnector(t, s)
+	rv, _, err := hs.transferOwnership(context.Background(), transferOwnershipArgs(t, "deals"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := rv.AsMap()
+	if result["total"] != float64(3) || result["remaining_total"] != float64(0) {
+		t.Fatalf("expected 3 records transferred and none left, got %v", result)
+	}
+
+	updates := 0
+	for _, request := range s.Requests() {
+		if request.Path == "/"+fmt.Sprintf(hubspot.CRMObjectBatchUpdateURL, "deals") {
+			updates++
+		}
+	}
+	if updates != 1 {
+		t.Fatalf("expected the deals to be updated once, got %d updates", updates)
+	}
+}
+
+func TestTransferOwnershipReportsRemainingRecords(t *testing.T) {
+	noTransferPassDelay(t)
+	s := ownershipServer(t)
+	for range 2 {
+		s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "101"}})
+	}
+	// the index never catches up
+	s.SetSearchLag(transferPassLimit * 10)
+
+	hs := newTestConnector(t, s)
+	rv, _, err := hs.transferOwnership(context.Background(), transferOwnershipArgs(t, "deals"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := rv.AsMap()
+	if result["total"] != float64(2) || result["remaining_total"] != float64(2) {
+		t.Fatalf("expected 2 records transferred and 2 still returned by the search, got %v", result)
+	}
+	remaining, _ := result["remaining"].(map[string]interface{})
+	if remaining["deals"] != float64(2) {
+		t.Fatalf("expected the remaining deals to be reported, got %v", remaining)
+	}
+}
@@ -3,53 +3,123 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/conductorone/baton-hubspot/pkg/hubspot"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 )
 
 const (
 	PageTypeDeleted   = "DELETED_USERS"
+	PageTypeLogins    = "LOGIN_ACTIVITY"
 	PageTypeAllUsers  = "ALL_USERS"
 	PageTypeCompleted = "COMPLETED"
 )
 
+const loginActivityPageSize = 100
+
 type userResourceType struct {
 	resourceType *v2.ResourceType
-	client       *hubspot.Client
+	portals      *portalSet
 	userStatus   bool
-	deletedSet   map[string]bool
-	setMtx       sync.Mutex
+	lastLogin    bool
+	// deletedSet and lastLogins are collected per account during the sync, keyed by HubSpot user ID
+	deletedSet map[string]map[string]bool
+	lastLogins map[string]map[string]time.Time
+	setMtx     sync.Mutex
 }
 
 func (u *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return u.resourceType
 }
 
-func (c *userResourceType) cacheUsers(ids []string) error {
+func (c *userResourceType) cacheUsers(accountId string, ids []string) error {
 	c.setMtx.Lock()
 	defer c.setMtx.Unlock()
 	if c.deletedSet == nil {
-		c.deletedSet = make(map[string]bool)
+		c.deletedSet = make(map[string]map[string]bool)
+	}
+	if c.deletedSet[accountId] == nil {
+		c.deletedSet[accountId] = make(map[string]bool)
 	}
 	for _, user := range ids {
-		c.deletedSet[user] = true
+		c.deletedSet[accountId][user] = true
 	}
 	return nil
 }
 
-// Create a new connector resource for an HubSpot user.
-func (c *userResourceType) userResource(ctx context.Context, user *hubspot.User, parentResourceID *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+// cacheLastLogins keeps the most recent successful login of every user of the account.
+func (c *userResourceType) cacheLastLogins(accountId string, activity []hubspot.LoginActivity) {
+	c.setMtx.Lock()
+	defer c.setMtx.Unlock()
+	if c.lastLogins == nil {
+		c.lastLogins = make(map[string]map[string]time.Time)
+	}
+	if c.lastLogins[accountId] == nil {
+		c.lastLogins[accountId] = make(map[string]time.Time)
+	}
+	for _, login := range activity {
+		if !login.Succeeded || login.UserId == "" {
+			continue
+		}
+		if login.LoginAt.After(c.lastLogins[accountId][login.UserId]) {
+			c.lastLogins[accountId][login.UserId] = login.LoginAt
+		}
+	}
+}
+
+func (c *userResourceType) clearCache(accountId string) {
+	c.setMtx.Lock()
+	defer c.setMtx.Unlock()
+	delete(c.deletedSet, accountId)
+	delete(c.lastLogins, accountId)
+}
+
+// usersPageType returns the pagination type that follows the deactivated users pass.
+func (u *userResourceType) usersPageType() string {
+	if u.lastLogin {
+		return PageTypeLogins
+	}
+
+	return PageTypeAllUsers
+}
+
+// userDetails are the user attributes not returned by the users API.
+type userDetails struct {
+	deactivated bool
+	lastLogin   *time.Time
+}
+
+// cachedUserDetails returns the details of the user of the account collected during the current sync.
+func (c *userResourceType) cachedUserDetails(accountId string, userId string) userDetails {
+	c.setMtx.Lock()
+	defer c.setMtx.Unlock()
+
+	details := userDetails{
+		deactivated: c.deletedSet[accountId][userId],
+	}
+	if lastLogin, ok := c.lastLogins[accountId][userId]; ok {
+		details.lastLogin = &lastLogin
+	}
+
+	return details
+}
+
+// Create a new connector resource for an HubSpot user, with the resource ID of the user in its portal.
+func userResource(user *hubspot.User, userResourceId *v2.ResourceId, details userDetails, parentResourceID *v2.ResourceId) (*v2.Resource, error) {
 	profile := map[string]interface{}{
 		"login":   user.Email,
 		"user_id": user.Id,
 	}
+
 	userState := v2.UserTrait_Status_STATUS_ENABLED
-	if c.deletedSet[user.Id] {
+	if details.deactivated {
 		userState = v2.UserTrait_Status_STATUS_DISABLED
 	}
 
@@ -59,27 +129,23 @@ func (c *userResourceType) userResource(ctx context.Context, user *hubspot.User,
 		rs.WithStatus(userState),
 	}
 
-	lastLogin, annos, err := c.client.GetUserLastLogin(ctx, user.Id)
-	if err != nil {
-		return nil, annos, fmt.Errorf("failed to get last login activity %w", err)
-	}
-	if lastLogin != nil {
-		userTraitOptions = append(userTraitOptions, rs.WithLastLogin(*lastLogin))
+	if details.lastLogin != nil {
+		userTraitOptions = append(userTraitOptions, rs.WithLastLogin(*details.lastLogin))
 	}
 
 	resource, err := rs.NewUserResource(
 		user.Email, // email as a name
 		resourceTypeUser,
-		user.Id,
+		userResourceId.Resource,
 		userTraitOptions,
 		rs.WithParentResourceID(parentResourceID),
 	)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return resource, nil, nil
+	return resource, nil
 }
 
 func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, token *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
@@ -91,25 +157,30 @@ func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 		return nil, "", nil, err
 	}
 
+	p, err := u.portals.forParent(ctx, parentId)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	userPageToken, err := unmarshalUserPageToken(bag.PageToken())
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to unmarshal the token %w", err)
 	}
 
 	if userPageToken.Type == "" && !u.userStatus {
-		userPageToken = &UsersPaginationToken{Page: "", Type: PageTypeAllUsers}
+		userPageToken = &UsersPaginationToken{Page: "", Type: u.usersPageType()}
 	}
 
 	switch userPageToken.Type {
 	case "", PageTypeDeleted:
 		// Paginate over deleted users and populate deleted set.
-		deletedIDs, nextToken, annotation, err := u.client.GetDeletedUsers(ctx,
+		deletedIDs, nextToken, annotation, err := p.client.GetDeletedUsers(ctx,
 			hubspot.GetUsersVars{Limit: ResourcesPageSize, After: userPageToken.Page},
 		)
 		if err != nil {
 			return nil, "", nil, fmt.Errorf("hubspot-connector: failed to get deactivated users: %w", err)
 		}
-		err = u.cacheUsers(deletedIDs)
+		err = u.cacheUsers(parentId.Resource, deletedIDs)
 		if err != nil {
 			return nil, "", nil, fmt.Errorf("hubspot-connector: failed to get deactivated users: %w", err)
 		}
@@ -123,9 +194,9 @@ func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 			}
 			return nil, parsedNextToken, annotation, nil
 		} else {
-			// no more deleted users, start PageTypeAllUsers pagination
+			// no more deleted users, continue with login activity or all users pagination
 			parsedNextToken, err := parseUserPaginationToken(
-				UsersPaginationToken{Page: "", Type: PageTypeAllUsers},
+				UsersPaginationToken{Page: "", Type: u.usersPageType()},
 				bag,
 			)
 			if err != nil {
@@ -133,8 +204,30 @@ func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 			}
 			return nil, parsedNextToken, annotation, nil
 		}
+	case PageTypeLogins:
+		// Paginate over login activity of all users and populate last login map.
+		activity, nextToken, annotation, err := p.client.GetLoginActivity(ctx,
+			hubspot.GetActivityVars{Limit: loginActivityPageSize, After: userPageToken.Page},
+		)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("hubspot-connector: failed to get login activity: %w", err)
+		}
+		u.cacheLastLogins(parentId.Resource, activity)
+
+		paginationType := PageTypeLogins
+		if nextToken == "" {
+			paginationType = PageTypeAllUsers
+		}
+		parsedNextToken, err := parseUserPaginationToken(
+			UsersPaginationToken{Page: nextToken, Type: paginationType},
+			bag,
+		)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return nil, parsedNextToken, annotation, nil
 	case PageTypeAllUsers:
-		users, nextToken, annotations, err := u.client.GetUsers(
+		users, nextToken, annotations, err := p.client.GetUsers(
 			ctx,
 			hubspot.GetUsersVars{Limit: ResourcesPageSize, After: userPageToken.Page},
 		)
@@ -156,9 +249,13 @@ func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 		var rv []*v2.Resource
 		for _, user := range users {
 			userCopy := user
-			ur, annos, err := u.userResource(ctx, &userCopy, parentId)
+			userResourceId, err := u.portals.userResourceId(ctx, p, userCopy.Id)
 			if err != nil {
-				return nil, "", annos, err
+				return nil, "", nil, err
+			}
+			ur, err := userResource(&userCopy, userResourceId, u.cachedUserDetails(parentId.Resource, userCopy.Id), parentId)
+			if err != nil {
+				return nil, "", nil, err
 			}
 
 			rv = append(rv, ur)
@@ -166,7 +263,7 @@ func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, to
 
 		return rv, parsedNextToken, annotations, nil
 	case PageTypeCompleted:
-		u.deletedSet = nil
+		u.clearCache(parentId.Resource)
 		return nil, "", nil, nil
 	}
 	return nil, "", nil, nil
@@ -180,10 +277,110 @@ func (u *userResourceType) Grants(ctx context.Context, resource *v2.Resource, to
 	return nil, "", nil, nil
 }
 
-func userBuilder(client *hubspot.Client, userStatus bool) *userResourceType {
+// Get returns a single user, its status and last login are fetched only for this user.
+func (u *userResourceType) Get(ctx context.Context, resourceId *v2.ResourceId, parentResourceId *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	p, userId, err := u.portals.forUser(ctx, resourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, annos, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, annos, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	var details userDetails
+	if u.userStatus {
+		details.deactivated, _, err = p.client.IsUserDeactivated(ctx, user.Id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hubspot-connector: failed to get user status: %w", err)
+		}
+	}
+
+	if u.lastLogin {
+		details.lastLogin, _, err = p.client.GetUserLastLogin(ctx, user.Id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hubspot-connector: failed to get last login activity: %w", err)
+		}
+	}
+
+	resource, err := userResource(&user, resourceId, details, parentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource, annos, nil
+}
+
+func (u *userResourceType) CreateAccountCapabilityDetails(_ context.Context) (*v2.CredentialDetailsAccountProvisioning, annotations.Annotations, error) {
+	return &v2.CredentialDetailsAccountProvisioning{
+		SupportedCredentialOptions: []v2.CapabilityDetailCredentialOption{
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+		},
+		PreferredCredentialOption: v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+	}, nil, nil
+}
+
+// CreateAccount creates a new HubSpot user in the primary portal, the account info does not
+// designate a portal. HubSpot handles the credentials itself, the user sets a password through
+// the welcome email or signs in with SSO.
+func (u *userResourceType) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	_ *v2.CredentialOptions,
+) (connectorbuilder.CreateAccountResponse, []*v2.PlaintextData, annotations.Annotations, error) {
+	payload, err := createUserPayload(accountInfo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	user, annos, err := u.portals.primary().client.CreateUser(ctx, payload)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("hubspot-connector: failed to create user: %w", err)
+	}
+
+	resource, err := userResource(&user, getUserResourceId(user.Id), userDetails{}, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &v2.CreateAccountResponse_SuccessResult{
+		Resource:              resource,
+		IsCreateAccountResult: true,
+	}, nil, annos, nil
+}
+
+// Delete removes the user from the portal its resource ID belongs to. The resource ID may also be
+// an email address of a user of the primary portal, which is used to look the user up when the
+// HubSpot user ID is not known.
+func (u *userResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("hubspot-connector: only users can be deleted")
+	}
+
+	p, userId, err := u.portals.forUser(ctx, resourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	var idProperty string
+	if strings.Contains(userId, "@") {
+		idProperty = hubspot.IdPropertyEmail
+	}
+
+	annos, err := p.client.DeleteUser(ctx, userId, idProperty)
+	if err != nil {
+		return nil, fmt.Errorf("hubspot-connector: failed to delete user: %w", err)
+	}
+
+	return annos, nil
+}
+
+func userBuilder(portals *portalSet, userStatus bool, lastLogin bool) *userResourceType {
 	return &userResourceType{
 		resourceType: resourceTypeUser,
-		client:       client,
+		portals:      portals,
 		userStatus:   userStatus,
+		lastLogin:    lastLogin,
 	}
 }
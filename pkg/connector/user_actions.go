@@ -0,0 +1,210 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	actionDeactivateUser   = "deactivate_user"
+	actionReactivateUser   = "reactivate_user"
+	actionSetPrimaryTeam   = "set_primary_team"
+	actionMakeSuperAdmin   = "make_super_admin"
+	actionRemoveSuperAdmin = "remove_super_admin"
+)
+
+func userIdArgField() *config.Field {
+	return stringArgField("user_id", "User ID", "ID of the user resource, qualified with the account ID for users of other portals than the primary one.", true)
+}
+
+func userIdReturnField() *config.Field {
+	return stringArgField("user_id", "User ID", "", false)
+}
+
+func deactivateUserSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionDeactivateUser,
+		DisplayName: "Deactivate user",
+		Description: "Deactivates the user, the user can no longer log in but keeps its settings and records.",
+		Arguments:   []*config.Field{userIdArgField()},
+		ReturnTypes: []*config.Field{userIdReturnField(), boolReturnField("deactivated", "Deactivated")},
+	}
+}
+
+func reactivateUserSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionReactivateUser,
+		DisplayName: "Reactivate user",
+		Description: "Restores the access of a deactivated user.",
+		Arguments:   []*config.Field{userIdArgField()},
+		ReturnTypes: []*config.Field{userIdReturnField(), boolReturnField("deactivated", "Deactivated")},
+	}
+}
+
+func setPrimaryTeamSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionSetPrimaryTeam,
+		DisplayName: "Set primary team",
+		Description: "Makes the team the primary team of the user, the previous primary team is replaced.",
+		Arguments: []*config.Field{
+			userIdArgField(),
+			stringArgField("team_id", "Team ID", "ID of the new primary team of the user.", true),
+		},
+		ReturnTypes: []*config.Field{
+			userIdReturnField(),
+			stringArgField("primary_team_id", "Primary team ID", "", false),
+			stringArgField("previous_team_id", "Previous primary team ID", "", false),
+		},
+	}
+}
+
+func makeSuperAdminSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionMakeSuperAdmin,
+		DisplayName: "Make super admin",
+		Description: "Grants the user super admin privileges in the account.",
+		Arguments:   []*config.Field{userIdArgField()},
+		ReturnTypes: []*config.Field{userIdReturnField(), boolReturnField("super_admin", "Super admin")},
+	}
+}
+
+func removeSuperAdminSchema() *v2.BatonActionSchema {
+	return &v2.BatonActionSchema{
+		Name:        actionRemoveSuperAdmin,
+		DisplayName: "Remove super admin",
+		Description: "Removes the super admin privileges of the user, its role and teams are kept.",
+		Arguments:   []*config.Field{userIdArgField()},
+		ReturnTypes: []*config.Field{userIdReturnField(), boolReturnField("super_admin", "Super admin")},
+	}
+}
+
+// actionUser returns the portal of the user the action operates on and its HubSpot ID, the user_id
+// argument being the ID of the user resource.
+func (hs *HubSpot) actionUser(ctx context.Context, args *structpb.Struct) (*portal, string, error) {
+	resourceId, err := stringArg(args, "user_id", true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return hs.portals.forUser(ctx, &v2.ResourceId{ResourceType: resourceTypeUser.Id, Resource: resourceId})
+}
+
+func (hs *HubSpot) deactivateUser(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return hs.setDeactivated(ctx, args, true)
+}
+
+func (hs *HubSpot) reactivateUser(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return hs.setDeactivated(ctx, args, false)
+}
+
+// setDeactivated updates the deactivation of the user, which is held by its record in the CRM users object.
+func (hs *HubSpot) setDeactivated(ctx context.Context, args *structpb.Struct, deactivated bool) (*structpb.Struct, annotations.Annotations, error) {
+	p, userId, err := hs.actionUser(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	object, _, err := p.client.FindUserObject(ctx, userId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubspot-connector: failed to get user record: %w", err)
+	}
+	if object == nil {
+		return nil, nil, status.Errorf(codes.NotFound, "hubspot-connector: user %s has no CRM user record", userId)
+	}
+
+	annos, err := p.client.SetUserObjectDeactivated(ctx, object.Id, deactivated)
+	if err != nil {
+		return nil, annos, fmt.Errorf("hubspot-connector: failed to update user record: %w", err)
+	}
+	p.users.Invalidate()
+
+	return userActionResult(args, map[string]interface{}{"deactivated": deactivated}, annos)
+}
+
+// setPrimaryTeam replaces the primary team of the user. When the new team was one of
+// the secondary teams of the user, it is no longer kept as a secondary team.
+func (hs *HubSpot) setPrimaryTeam(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	p, userId, err := hs.actionUser(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	teamId, err := stringArg(args, "team_id", true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	payload := updateUserPayload(&user)
+	payload.PrimaryTeamId = teamId
+	payload.SecondaryTeamIDs = removeTeam(user.SecondaryTeamIDs, teamId)
+
+	annos, err := p.client.UpdateUser(ctx, userId, payload)
+	if err != nil {
+		return nil, annos, fmt.Errorf("hubspot-connector: failed to update user: %w", err)
+	}
+	p.users.Invalidate()
+
+	return userActionResult(args, map[string]interface{}{
+		"primary_team_id":  teamId,
+		"previous_team_id": user.TeamId,
+	}, annos)
+}
+
+func (hs *HubSpot) makeSuperAdmin(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return hs.updateSuperAdmin(ctx, args, true)
+}
+
+func (hs *HubSpot) removeSuperAdmin(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return hs.updateSuperAdmin(ctx, args, false)
+}
+
+func (hs *HubSpot) updateSuperAdmin(ctx context.Context, args *structpb.Struct, superAdmin bool) (*structpb.Struct, annotations.Annotations, error) {
+	p, userId, err := hs.actionUser(ctx, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, _, err := p.client.GetUser(ctx, userId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubspot-connector: failed to get user: %w", err)
+	}
+
+	if !superAdmin && user.SuperAdmin {
+		err = ensureOtherSuperAdmin(ctx, p.users, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	annos, err := setSuperAdmin(ctx, p.client, &user, superAdmin)
+	if err != nil {
+		return nil, annos, err
+	}
+	p.users.Invalidate()
+
+	return userActionResult(args, map[string]interface{}{"super_admin": superAdmin}, annos)
+}
+
+// userActionResult builds the result of an action performed on a single user, the user ID
+// of the arguments is returned as is.
+func userActionResult(args *structpb.Struct, fields map[string]interface{}, annos annotations.Annotations) (*structpb.Struct, annotations.Annotations, error) {
+	fields["user_id"] = args.GetFields()["user_id"].GetStringValue()
+
+	rv, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return rv, annos, nil
+}
@@ -0,0 +1,166 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func actionArgs(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+
+	args, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return args
+}
+
+func TestCustomActions(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	var names []string
+	for _, action := range newTestConnector(t, s).customActions() {
+		names = append(names, action.schema.Name)
+	}
+
+	want := []string{
+		actionTransferOwnership,
+		actionDeactivateUser,
+		actionReactivateUser,
+		actionSetPrimaryTeam,
+		actionMakeSuperAdmin,
+		actionRemoveSuperAdmin,
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("got actions %v, want %v", names, want)
+	}
+}
+
+func TestDeactivateAndReactivateUser(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	hs := newTestConnector(t, s)
+	args := actionArgs(t, map[string]interface{}{"user_id": user.Id})
+
+	rv, _, err := hs.deactivateUser(ctx, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := rv.AsMap(); result["deactivated"] != true || result["user_id"] != user.Id {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if !s.Deactivated(user.Id) {
+		t.Fatal("expected the user to be deactivated")
+	}
+
+	if _, _, err := hs.reactivateUser(ctx, args); err != nil {
+		t.Fatal(err)
+	}
+	if s.Deactivated(user.Id) {
+		t.Fatal("expected the user to be reactivated")
+	}
+
+	_, _, err = hs.deactivateUser(ctx, actionArgs(t, map[string]interface{}{"user_id": "404"}))
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a user without a CRM user record, got %v", err)
+	}
+}
+
+func TestUserActionsUseThePortalOfTheUser(t *testing.T) {
+	ctx := context.Background()
+	primary, other, portals := twoPortals(t)
+	hs := &HubSpot{portals: portals}
+
+	// the same HubSpot ID in both portals
+	primary.AddUser(hubspot.User{Email: "jane@example.com"})
+	user := other.AddUser(hubspot.User{Email: "john@example.com"})
+	userId := user.Id + ":67890"
+
+	rv, _, err := hs.deactivateUser(ctx, actionArgs(t, map[string]interface{}{"user_id": userId}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := rv.AsMap(); result["user_id"] != userId {
+		t.Fatalf("expected the user resource ID in the result, got %v", result)
+	}
+	if !other.Deactivated(user.Id) || primary.Deactivated(user.Id) {
+		t.Fatal("expected the user of the other portal to be deactivated")
+	}
+
+	other.AddUser(hubspot.User{Email: "admin@example.com", SuperAdmin: true})
+	if _, _, err := hs.makeSuperAdmin(ctx, actionArgs(t, map[string]interface{}{"user_id": userId})); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := other.User(user.Id); !updated.SuperAdmin {
+		t.Fatal("expected the user of the other portal to be a super admin")
+	}
+	if updated, _ := primary.User(user.Id); updated.SuperAdmin {
+		t.Fatal("expected the user of the primary portal to be left as is")
+	}
+}
+
+func TestSetPrimaryTeam(t *testing.T) {
+	s, user, teams := teamMembershipServer(t)
+	hs := newTestConnector(t, s)
+
+	// the secondary team becomes the primary team
+	rv, _, err := hs.setPrimaryTeam(context.Background(), actionArgs(t, map[string]interface{}{
+		"user_id": user.Id,
+		"team_id": teams[1].Id,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := rv.AsMap()
+	if result["primary_team_id"] != teams[1].Id || result["previous_team_id"] != teams[0].Id {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	updated, _ := s.User(user.Id)
+	if updated.TeamId != teams[1].Id || len(updated.SecondaryTeamIDs) != 0 {
+		t.Fatalf("expected only the new primary team, got %+v", updated)
+	}
+	if !slices.Equal(updated.RoleIDs, user.RoleIDs) {
+		t.Fatalf("expected the role to be kept, got %v", updated.RoleIDs)
+	}
+}
+
+func TestSuperAdminActions(t *testing.T) {
+	ctx := context.Background()
+	s, user, _ := teamMembershipServer(t)
+	hs := newTestConnector(t, s)
+	args := actionArgs(t, map[string]interface{}{"user_id": user.Id})
+
+	if _, _, err := hs.makeSuperAdmin(ctx, args); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := s.User(user.Id)
+	if !updated.SuperAdmin || updated.TeamId != user.TeamId {
+		t.Fatalf("expected a super admin keeping its teams, got %+v", updated)
+	}
+
+	// the only super admin of the account is kept
+	_, _, err := hs.removeSuperAdmin(ctx, args)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+
+	s.AddUser(hubspot.User{Email: "admin@example.com", SuperAdmin: true})
+	if _, _, err := hs.removeSuperAdmin(ctx, args); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ = s.User(user.Id)
+	if updated.SuperAdmin {
+		t.Fatal("expected the super admin privileges to be removed")
+	}
+}
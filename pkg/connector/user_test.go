@@ -0,0 +1,348 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestParseQualifiedId(t *testing.T) {
+	tests := []struct {
+		resourceId string
+		userId     string
+		accountId  string
+	}{
+		{resourceId: "1000", userId: "1000"},
+		{resourceId: "1000:67890", userId: "1000", accountId: "67890"},
+		{resourceId: "jane@example.com", userId: "jane@example.com"},
+	}
+
+	for _, tt := range tests {
+		userId, accountId := parseQualifiedId(tt.resourceId)
+		if userId != tt.userId || accountId != tt.accountId {
+			t.Errorf("parseQualifiedId(%q) = %q, %q, want %q, %q", tt.resourceId, userId, accountId, tt.userId, tt.accountId)
+		}
+	}
+}
+
+func userStatus(t *testing.T, resource *v2.Resource) v2.UserTrait_Status_Status {
+	t.Helper()
+
+	userTrait, err := rs.GetUserTrait(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userTrait.GetStatus().GetStatus()
+}
+
+func TestUsersOfOtherPortalsAreQualified(t *testing.T) {
+	ctx := context.Background()
+	primary, other, portals := twoPortals(t)
+	builder := userBuilder(portals, true, false)
+
+	// both portals number their users the same way
+	jane := primary.AddUser(hubspot.User{Email: "jane@example.com"})
+	john := other.AddUser(hubspot.User{Email: "john@example.com"})
+	if jane.Id != john.Id {
+		t.Fatalf("expected the users to have the same ID, got %s and %s", jane.Id, john.Id)
+	}
+	primary.SetDeactivated(jane.Id, true)
+
+	users := listAll(t, builder, accountId(hubspottest.DefaultPortalId))
+	if len(users) != 1 || users[0].Id.Resource != jane.Id {
+		t.Fatalf("expected user %s in the primary portal, got %v", jane.Id, users)
+	}
+	if userStatus(t, users[0]) != v2.UserTrait_Status_STATUS_DISABLED {
+		t.Fatal("expected the user of the primary portal to be disabled")
+	}
+
+	users = listAll(t, builder, accountId(67890))
+	if len(users) != 1 || users[0].Id.Resource != john.Id+":67890" {
+		t.Fatalf("expected user %s:67890 in the other portal, got %v", john.Id, users)
+	}
+	// the status of the user of the primary portal is not applied to the user with the same ID
+	if userStatus(t, users[0]) != v2.UserTrait_Status_STATUS_ENABLED {
+		t.Fatal("expected the user of the other portal to be enabled")
+	}
+
+	resource, _, err := builder.Get(ctx, users[0].Id, accountId(67890))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.DisplayName != john.Email || resource.Id.Resource != users[0].Id.Resource {
+		t.Fatalf("expected %s, got %v", john.Email, resource)
+	}
+
+	if _, err := builder.Delete(ctx, users[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.User(john.Id); ok {
+		t.Fatal("expected the user to be deleted from the other portal")
+	}
+	if _, ok := primary.User(jane.Id); !ok {
+		t.Fatal("expected the user of the primary portal to be kept")
+	}
+}
+
+func TestGrantsUseTheUserOfTheEntitlementPortal(t *testing.T) {
+	ctx := context.Background()
+	primary, other, portals := twoPortals(t)
+	builder := teamBuilder(portals, false)
+
+	team := other.AddTeam(hubspot.Team{Name: "Sales"})
+	support := other.AddTeam(hubspot.Team{Name: "Support"})
+	john := other.AddUser(hubspot.User{Email: "john@example.com", TeamId: team.Id})
+	jane := primary.AddUser(hubspot.User{Email: "jane@example.com"})
+
+	var sales *v2.Resource
+	for _, resource := range listAll(t, builder, accountId(67890)) {
+		if resource.Id.Resource == team.Id+":67890" {
+			sales = resource
+		}
+	}
+	if sales == nil {
+		t.Fatalf("expected team %s in the other portal", team.Id)
+	}
+
+	want := []string{"team:" + team.Id + ":67890:" + primaryMemberEntitlement + "/" + john.Id + ":67890"}
+	if got := grantsOf(t, builder, sales); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("got grants %v, want %v", got, want)
+	}
+
+	entitlement := &v2.Entitlement{
+		Resource: &v2.Resource{
+			Id:               &v2.ResourceId{ResourceType: resourceTypeTeam.Id, Resource: support.Id + ":67890"},
+			ParentResourceId: accountId(67890),
+		},
+		Slug: secondaryMemberEntitlement,
+	}
+	if _, err := builder.Grant(ctx, userPrincipal(john.Id+":67890"), entitlement); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := other.User(john.Id)
+	if updated.TeamId != team.Id || len(updated.SecondaryTeamIDs) != 1 || updated.SecondaryTeamIDs[0] != support.Id {
+		t.Fatalf("expected the secondary team to be added, got %+v", updated)
+	}
+
+	// users of the primary portal cannot be granted the teams of another portal
+	_, err := builder.Grant(ctx, userPrincipal(jane.Id), entitlement)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func accountInfo(t *testing.T, profile map[string]interface{}) *v2.AccountInfo {
+	t.Helper()
+
+	pb, err := structpb.NewStruct(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &v2.AccountInfo{Profile: pb}
+}
+
+func TestCreateAccount(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	team := s.AddTeam(hubspot.Team{Name: "Sales"})
+	role := s.AddRole(hubspot.Role{Name: "Sales Rep"})
+	builder := userBuilder(newTestConnector(t, s).portals, false, false)
+
+	response, _, _, err := builder.CreateAccount(ctx, accountInfo(t, map[string]interface{}{
+		"email":              "jane@example.com",
+		"first_name":         "Jane",
+		"last_name":          "Doe",
+		"role_id":            role.Id,
+		"primary_team_id":    team.Id,
+		"send_welcome_email": true,
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := response.(*v2.CreateAccountResponse_SuccessResult)
+	if !ok || !result.IsCreateAccountResult {
+		t.Fatalf("expected a created account, got %v", response)
+	}
+
+	users := s.Users()
+	if len(users) != 1 {
+		t.Fatalf("expected a single user, got %v", users)
+	}
+	user := users[0]
+	if result.Resource.Id.Resource != user.Id || result.Resource.DisplayName != "jane@example.com" {
+		t.Fatalf("expected the resource of user %s, got %v", user.Id, result.Resource)
+	}
+	if user.FirstName != "Jane" || user.LastName != "Doe" || user.TeamId != team.Id || len(user.RoleIDs) != 1 || user.RoleIDs[0] != role.Id {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if emails := s.WelcomeEmails(); len(emails) != 1 || emails[0] != "jane@example.com" {
+		t.Fatalf("expected the welcome email to be sent, got %v", emails)
+	}
+}
+
+func TestCreateAccountEmail(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountInfo *v2.AccountInfo
+		want        string
+	}{
+		{
+			name: "primary email",
+			accountInfo: &v2.AccountInfo{Emails: []*v2.AccountInfo_Email{
+				{Address: "jane.doe@example.com"},
+				{Address: "jane@example.com", IsPrimary: true},
+			}},
+			want: "jane@example.com",
+		},
+		{
+			name:        "login",
+			accountInfo: &v2.AccountInfo{Login: "jane@example.com"},
+			want:        "jane@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := hubspottest.NewServer()
+			defer s.Close()
+
+			builder := userBuilder(newTestConnector(t, s).portals, false, false)
+			if _, _, _, err := builder.CreateAccount(context.Background(), tt.accountInfo, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			users := s.Users()
+			if len(users) != 1 || users[0].Email != tt.want {
+				t.Fatalf("expected user %s, got %v", tt.want, users)
+			}
+			// the welcome email is not sent unless requested
+			if emails := s.WelcomeEmails(); len(emails) != 0 {
+				t.Fatalf("expected no welcome email, got %v", emails)
+			}
+		})
+	}
+}
+
+func TestCreateAccountErrors(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.AddUser(hubspot.User{Email: "jane@example.com"})
+	builder := userBuilder(newTestConnector(t, s).portals, false, false)
+
+	if _, _, _, err := builder.CreateAccount(ctx, accountInfo(t, map[string]interface{}{"first_name": "Jane"}), nil); err == nil {
+		t.Fatal("expected an error without an email")
+	}
+	if requests := s.Requests(); len(requests) != 0 {
+		t.Fatalf("expected the account info to be rejected before any request, got %v", requests)
+	}
+
+	_, _, _, err := builder.CreateAccount(ctx, accountInfo(t, map[string]interface{}{"email": "jane@example.com"}), nil)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists for an existing user, got %v", err)
+	}
+
+	if users := s.Users(); len(users) != 1 {
+		t.Fatalf("expected no user to be created, got %v", users)
+	}
+}
+
+func TestDeleteUserByEmail(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com"})
+	john := s.AddUser(hubspot.User{Email: "john@example.com"})
+	builder := userBuilder(newTestConnector(t, s).portals, false, false)
+
+	if _, err := builder.Delete(context.Background(), getUserResourceId(jane.Email)); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := s.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected a single request, got %v", requests)
+	}
+	if request := requests[0]; request.Method != "DELETE" || request.Query.Get("idProperty") != hubspot.IdPropertyEmail {
+		t.Fatalf("expected the user to be deleted by email, got %+v", request)
+	}
+
+	if _, ok := s.User(jane.Id); ok {
+		t.Fatal("expected the user to be deleted")
+	}
+	if _, ok := s.User(john.Id); !ok {
+		t.Fatal("expected the other user to be kept")
+	}
+}
+
+func TestUsersLastLogin(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	jane := s.AddUser(hubspot.User{Email: "jane@example.com"})
+	john := s.AddUser(hubspot.User{Email: "john@example.com"})
+	never := s.AddUser(hubspot.User{Email: "never@example.com"})
+
+	// the logins of jane span several pages of login activity, the oldest one being the most recent success
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	janeLogin := start.Add(time.Hour)
+	s.AddLoginActivity(hubspot.LoginActivity{UserId: jane.Id, LoginAt: janeLogin, Succeeded: true})
+	for i := 0; i < 2*loginActivityPageSize; i++ {
+		s.AddLoginActivity(hubspot.LoginActivity{UserId: jane.Id, LoginAt: janeLogin.Add(time.Duration(i+1) * time.Minute)})
+	}
+	johnLogin := start.Add(2 * time.Hour)
+	s.AddLoginActivity(hubspot.LoginActivity{UserId: john.Id, LoginAt: start, Succeeded: true})
+	s.AddLoginActivity(hubspot.LoginActivity{UserId: john.Id, LoginAt: johnLogin, Succeeded: true})
+	s.AddLoginActivity(hubspot.LoginActivity{UserId: never.Id, LoginAt: johnLogin})
+
+	users := listAll(t, userBuilder(newTestConnector(t, s).portals, false, true), accountId(hubspottest.DefaultPortalId))
+
+	lastLogins := make(map[string]*time.Time)
+	for _, user := range users {
+		userTrait, err := rs.GetUserTrait(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userTrait.LastLogin != nil {
+			lastLogin := userTrait.LastLogin.AsTime()
+			lastLogins[user.Id.Resource] = &lastLogin
+		}
+	}
+
+	if lastLogin := lastLogins[jane.Id]; lastLogin == nil || !lastLogin.Equal(janeLogin) {
+		t.Fatalf("expected the last login of jane at %v, got %v", janeLogin, lastLogin)
+	}
+	if lastLogin := lastLogins[john.Id]; lastLogin == nil || !lastLogin.Equal(johnLogin) {
+		t.Fatalf("expected the last login of john at %v, got %v", johnLogin, lastLogin)
+	}
+	if lastLogin, ok := lastLogins[never.Id]; ok {
+		t.Fatalf("expected no last login without a successful login, got %v", lastLogin)
+	}
+
+	var loginPages int
+	for _, request := range s.Requests() {
+		if request.Path == "/"+hubspot.AccountLastLogin {
+			if request.Query.Get("userId") != "" {
+				t.Fatalf("expected the login activity to be fetched for all users, got %+v", request)
+			}
+			loginPages++
+		}
+	}
+	if loginPages != 3 {
+		t.Fatalf("expected 3 pages of login activity, got %d", loginPages)
+	}
+}
@@ -9,29 +9,55 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// BaseURL is the default base URL of the HubSpot API, EUBaseURL serves portals hosted in the EU data center.
+// The endpoints below are relative to the base URL of the client.
 const BaseURL = "https://api.hubapi.com/"
-const UsersBaseURL = BaseURL + "settings/v3/users"
-const UserBaseURL = BaseURL + "settings/v3/users/%s"
-const TeamsBaseURL = BaseURL + "settings/v3/users/teams"
-const RolesBaseURL = BaseURL + "settings/v3/users/roles"
-const AccountBaseURL = BaseURL + "account-info/v3/details"
-const SearchUserObjectURL = BaseURL + "crm/v3/objects/users/search"
-const AccountLastLogin = BaseURL + "account-info/v3/activity/login"
+const EUBaseURL = "https://api-eu1.hubapi.com/"
+const UsersBaseURL = "settings/v3/users"
+const UserBaseURL = "settings/v3/users/%s"
+const TeamsBaseURL = "settings/v3/users/teams"
+const RolesBaseURL = "settings/v3/users/roles"
+const AccountBaseURL = "account-info/v3/details"
+const SandboxesBaseURL = "sandboxes/v1/sandboxes"
+const SearchUserObjectURL = "crm/v3/objects/users/search"
+const UserObjectURL = "crm/v3/objects/users/%s"
+const OwnersBaseURL = "crm/v3/owners"
+const OwnerBaseURL = "crm/v3/owners/%s"
+const CRMObjectSearchURL = "crm/v3/objects/%s/search"
+const CRMObjectBatchUpdateURL = "crm/v3/objects/%s/batch/update"
+const AccountLastLogin = "account-info/v3/activity/login"
+const AccountAuditLogs = "account-info/v3/activity/audit-logs"
+const AccountSecurityActivity = "account-info/v3/activity/security"
+const OAuthTokenURL = "oauth/v1/token"
+const UserBusinessUnitsURL = "business-units/v3/business-units/user/%s"
+const BusinessUnitUserURL = "business-units/v3/business-units/%s/users/%s"
+const OAuthAccessTokenInfoURL = "oauth/v1/access-tokens/%s"
+const PrivateAppTokenInfoURL = "oauth/v2/private-apps/get/access-token-info"
 const EqualOperator = "EQ"
+const IdPropertyEmail = "EMAIL"
 const HSInternalUserId = "hs_internal_user_id"
+const HSOwnerId = "hubspot_owner_id"
+const IdPropertyUserId = "userId"
+
+// MaxBatchSize is the maximum number of inputs accepted by the CRM batch endpoints.
+const MaxBatchSize = 100
 
 type Client struct {
-	httpClient  *http.Client
-	accessToken string
+	httpClient     *http.Client
+	accessToken    string
+	oauth          *OAuthCredentials
+	tokenMtx       sync.Mutex
+	tokenExpiresAt time.Time
+	baseURL        string
 }
 
 type UsersResponse struct {
@@ -45,8 +71,82 @@ type AccountLoginResponse struct {
 }
 
 type LoginActivity struct {
-	LoginAt   time.Time `json:"loginAt,omitempty"`
-	Succeeded bool      `json:"loginSucceeded,omitempty"`
+	Id          string    `json:"id,omitempty"`
+	UserId      string    `json:"userId,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	LoginAt     time.Time `json:"loginAt,omitempty"`
+	Succeeded   bool      `json:"loginSucceeded,omitempty"`
+	IpAddress   string    `json:"ipAddress,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	CountryCode string    `json:"countryCode,omitempty"`
+}
+
+type SecurityActivityResponse struct {
+	Results []SecurityActivity `json:"results"`
+	Paging  PaginationData     `json:"paging"`
+}
+
+type SecurityActivity struct {
+	Id         string     `json:"id"`
+	Type       string     `json:"type"`
+	UserId     string     `json:"userId,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	IpAddress  string     `json:"ipAddress,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	ActingUser ActingUser `json:"actingUser,omitempty"`
+}
+
+type AuditLogsResponse struct {
+	Results []AuditLog     `json:"results"`
+	Paging  PaginationData `json:"paging"`
+}
+
+type GetActivityVars struct {
+	Limit         int
+	After         string
+	OccurredAfter time.Time
+}
+
+type OwnersResponse struct {
+	Results []Owner        `json:"results"`
+	Paging  PaginationData `json:"paging"`
+}
+
+type GetOwnersVars struct {
+	Limit    int
+	After    string
+	Archived bool
+}
+
+type CRMObjectsResponse struct {
+	Total   int            `json:"total"`
+	Results []CRMObject    `json:"results"`
+	Paging  PaginationData `json:"paging"`
+}
+
+type CRMObjectInput struct {
+	Id         string            `json:"id"`
+	Properties map[string]string `json:"properties"`
+}
+
+type CRMBatchPayload struct {
+	Inputs []CRMObjectInput `json:"inputs"`
+}
+
+// CRMBatchError is an error of a CRM batch request, the IDs of the failed inputs are listed in its context.
+type CRMBatchError struct {
+	Status   string              `json:"status"`
+	Category string              `json:"category"`
+	Message  string              `json:"message"`
+	Context  map[string][]string `json:"context,omitempty"`
+}
+
+type CRMBatchResponse struct {
+	Status    string          `json:"status"`
+	Results   []CRMObject     `json:"results"`
+	Errors    []CRMBatchError `json:"errors,omitempty"`
+	NumErrors int             `json:"numErrors,omitempty"`
 }
 
 type GetUsersVars struct {
@@ -58,15 +158,27 @@ type TeamsResponse struct {
 	Results []Team `json:"results"`
 }
 
+type BusinessUnitsResponse struct {
+	Results []BusinessUnit `json:"results"`
+}
+
 type RolesResponse struct {
 	Results []Role `json:"results"`
 }
 
+type SandboxesResponse struct {
+	Results []Sandbox `json:"results"`
+}
+
 type SearchUserObjectResponse struct {
 	Results []UserObject   `json:"results"`
 	Paging  PaginationData `json:"paging"`
 }
 
+type UpdateUserObjectPayload struct {
+	Properties UserObjectProperties `json:"properties"`
+}
+
 type Filters struct {
 	Filters []Filter `json:"filters,omitempty"`
 }
@@ -84,11 +196,58 @@ type SearchUserObjectPayload struct {
 	After        string    `json:"after,omitempty"`
 }
 
-func NewClient(accessToken string, httpClient *http.Client) *Client {
-	return &Client{
+func NewClient(accessToken string, httpClient *http.Client, opts ...ClientOption) *Client {
+	c := &Client{
 		accessToken: accessToken,
 		httpClient:  httpClient,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// ClientOption configures optional settings of a client.
+type ClientOption func(c *Client)
+
+// WithBaseURL makes the client send requests to another base URL than BaseURL, such as EUBaseURL,
+// a proxy or a fake server in tests. The base URL is expected to be normalized with ParseBaseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// ParseBaseURL validates the base URL and normalizes it with a trailing slash,
+// the path of the base URL is kept so that the API can be served under a prefix.
+func ParseBaseURL(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("hubspot: invalid base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("hubspot: invalid base URL %q, an http or https URL is required", baseURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("hubspot: invalid base URL %q, query and fragment are not supported", baseURL)
+	}
+
+	return strings.TrimSuffix(u.String(), "/") + "/", nil
+}
+
+// BaseURL returns the base URL the requests of the client are sent to.
+func (c *Client) BaseURL() string {
+	if c.baseURL == "" {
+		return BaseURL
+	}
+
+	return c.baseURL
+}
+
+// endpoint returns the address of the endpoint on the base URL of the client.
+func (c *Client) endpoint(path string) string {
+	return c.BaseURL() + path
 }
 
 func setupPaginationQuery(query url.Values, limit int, after string) url.Values {
@@ -105,6 +264,16 @@ func setupPaginationQuery(query url.Values, limit int, after string) url.Values
 	return query
 }
 
+func setupActivityQuery(vars GetActivityVars) url.Values {
+	query := setupPaginationQuery(url.Values{}, vars.Limit, vars.After)
+
+	if !vars.OccurredAfter.IsZero() {
+		query.Add("occurredAfter", vars.OccurredAfter.UTC().Format(time.RFC3339Nano))
+	}
+
+	return query
+}
+
 // GetUsers returns all users for a single workspace.
 func (c *Client) GetUsers(ctx context.Context, getUsersVars GetUsersVars) ([]User, string, annotations.Annotations, error) {
 	queryParams := setupPaginationQuery(url.Values{}, getUsersVars.Limit, getUsersVars.After)
@@ -118,7 +287,7 @@ func (c *Client) GetUsers(ctx context.Context, getUsersVars GetUsersVars) ([]Use
 	)
 
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", annos, err
 	}
 
 	if (userResponse.Paging != PaginationData{}) {
@@ -128,6 +297,59 @@ func (c *Client) GetUsers(ctx context.Context, getUsersVars GetUsersVars) ([]Use
 	return userResponse.Results, "", annos, nil
 }
 
+// GetOwners returns a page of CRM owners, either active or archived ones.
+func (c *Client) GetOwners(ctx context.Context, vars GetOwnersVars) ([]Owner, string, annotations.Annotations, error) {
+	queryParams := setupPaginationQuery(url.Values{}, vars.Limit, vars.After)
+	queryParams.Add("archived", strconv.FormatBool(vars.Archived))
+
+	var ownersResponse OwnersResponse
+	annos, err := c.get(
+		ctx,
+		OwnersBaseURL,
+		&ownersResponse,
+		queryParams,
+	)
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	if (ownersResponse.Paging != PaginationData{}) {
+		return ownersResponse.Results, ownersResponse.Paging.Next.After, annos, nil
+	}
+
+	return ownersResponse.Results, "", annos, nil
+}
+
+// GetOwnerByUserId returns the CRM owner linked to the HubSpot user, archived owners included.
+func (c *Client) GetOwnerByUserId(ctx context.Context, userId string) (Owner, annotations.Annotations, error) {
+	queryParams := url.Values{}
+	queryParams.Add("idProperty", IdPropertyUserId)
+
+	var owner Owner
+	annos, err := c.get(
+		ctx,
+		fmt.Sprintf(OwnerBaseURL, url.PathEscape(userId)),
+		&owner,
+		queryParams,
+	)
+	if err == nil || !IsNotFound(err) {
+		return owner, annos, err
+	}
+
+	queryParams.Add("archived", "true")
+	annos, err = c.get(
+		ctx,
+		fmt.Sprintf(OwnerBaseURL, url.PathEscape(userId)),
+		&owner,
+		queryParams,
+	)
+	if err != nil {
+		return Owner{}, annos, err
+	}
+
+	return owner, annos, nil
+}
+
 // GetTeams returns all teams for a single account.
 func (c *Client) GetTeams(ctx context.Context) ([]Team, annotations.Annotations, error) {
 	var teamResponse TeamsResponse
@@ -139,7 +361,7 @@ func (c *Client) GetTeams(ctx context.Context) ([]Team, annotations.Annotations,
 	)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, annos, err
 	}
 
 	return teamResponse.Results, annos, nil
@@ -156,12 +378,23 @@ func (c *Client) GetAccount(ctx context.Context) (Account, annotations.Annotatio
 	)
 
 	if err != nil {
-		return Account{}, nil, err
+		return Account{}, annos, err
 	}
 
 	return accountResponse, annos, nil
 }
 
+// GetSandboxes returns the standard and development sandboxes of the production account.
+func (c *Client) GetSandboxes(ctx context.Context) ([]Sandbox, annotations.Annotations, error) {
+	var sandboxesResponse SandboxesResponse
+	annos, err := c.get(ctx, SandboxesBaseURL, &sandboxesResponse, nil)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return sandboxesResponse.Results, annos, nil
+}
+
 // GetUser returns information about a single user.
 func (c *Client) GetUser(ctx context.Context, userId string) (User, annotations.Annotations, error) {
 	var userResponse User
@@ -172,7 +405,7 @@ func (c *Client) GetUser(ctx context.Context, userId string) (User, annotations.
 		nil,
 	)
 	if err != nil {
-		return User{}, nil, err
+		return User{}, annos, err
 	}
 
 	return userResponse, annos, nil
@@ -183,16 +416,89 @@ func (c *Client) GetRoles(ctx context.Context) ([]Role, annotations.Annotations,
 	var rolesResponse RolesResponse
 	annos, err := c.get(ctx, RolesBaseURL, &rolesResponse, nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, annos, err
 	}
 
 	return rolesResponse.Results, annos, nil
 }
 
+// GetUserBusinessUnits returns the business units the user is assigned to.
+func (c *Client) GetUserBusinessUnits(ctx context.Context, userId string) ([]BusinessUnit, annotations.Annotations, error) {
+	var businessUnitsResponse BusinessUnitsResponse
+	annos, err := c.get(ctx, fmt.Sprintf(UserBusinessUnitsURL, url.PathEscape(userId)), &businessUnitsResponse, nil)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	return businessUnitsResponse.Results, annos, nil
+}
+
+// AddUserToBusinessUnit assigns the user to the business unit.
+func (c *Client) AddUserToBusinessUnit(ctx context.Context, businessUnitId string, userId string) (annotations.Annotations, error) {
+	annos, err := c.put(
+		ctx,
+		fmt.Sprintf(BusinessUnitUserURL, url.PathEscape(businessUnitId), url.PathEscape(userId)),
+		nil,
+		nil,
+	)
+	if err != nil {
+		return annos, err
+	}
+
+	return annos, nil
+}
+
+// RemoveUserFromBusinessUnit removes the user from the business unit.
+func (c *Client) RemoveUserFromBusinessUnit(ctx context.Context, businessUnitId string, userId string) (annotations.Annotations, error) {
+	annos, err := c.delete(
+		ctx,
+		fmt.Sprintf(BusinessUnitUserURL, url.PathEscape(businessUnitId), url.PathEscape(userId)),
+		nil,
+	)
+	if err != nil {
+		return annos, err
+	}
+
+	return annos, nil
+}
+
+// UpdateUserPayload is the assignment of a user. The role and the primary team are sent only when set,
+// ClearRole and ClearPrimaryTeam send them empty to remove them. The secondary teams are always sent,
+// the super admin flag is changed only when set.
 type UpdateUserPayload struct {
 	RoleId           string   `json:"roleId,omitempty"`
 	PrimaryTeamId    string   `json:"primaryTeamId,omitempty"`
-	SecondaryTeamIDs []string `json:"secondaryTeamIds,omitempty"`
+	SecondaryTeamIDs []string `json:"secondaryTeamIds"`
+	SuperAdmin       *bool    `json:"superAdmin,omitempty"`
+	ClearRole        bool     `json:"-"`
+	ClearPrimaryTeam bool     `json:"-"`
+}
+
+func (p UpdateUserPayload) MarshalJSON() ([]byte, error) {
+	type payload UpdateUserPayload
+
+	// the fields of the embedded payload are shadowed by the ones sent empty when cleared
+	return json.Marshal(struct {
+		payload
+		RoleId        *string `json:"roleId,omitempty"`
+		PrimaryTeamId *string `json:"primaryTeamId,omitempty"`
+	}{
+		payload:       payload(p),
+		RoleId:        clearableField(p.RoleId, p.ClearRole),
+		PrimaryTeamId: clearableField(p.PrimaryTeamId, p.ClearPrimaryTeam),
+	})
+}
+
+// clearableField returns the value to send for a field omitted when empty, unless it is cleared.
+func clearableField(value string, cleared bool) *string {
+	switch {
+	case cleared:
+		return new(string)
+	case value != "":
+		return &value
+	default:
+		return nil
+	}
 }
 
 // UpdateUser updates information about provided user.
@@ -204,7 +510,53 @@ func (c *Client) UpdateUser(ctx context.Context, userId string, payload *UpdateU
 		nil,
 	)
 	if err != nil {
-		return nil, err
+		return annos, err
+	}
+
+	return annos, nil
+}
+
+type CreateUserPayload struct {
+	Email            string `json:"email"`
+	FirstName        string `json:"firstName,omitempty"`
+	LastName         string `json:"lastName,omitempty"`
+	RoleId           string `json:"roleId,omitempty"`
+	PrimaryTeamId    string `json:"primaryTeamId,omitempty"`
+	SendWelcomeEmail bool   `json:"sendWelcomeEmail"`
+}
+
+// CreateUser creates a new user in the account.
+func (c *Client) CreateUser(ctx context.Context, payload *CreateUserPayload) (User, annotations.Annotations, error) {
+	var userResponse User
+	annos, err := c.post(
+		ctx,
+		UsersBaseURL,
+		payload,
+		&userResponse,
+	)
+	if err != nil {
+		return User{}, annos, err
+	}
+
+	return userResponse, annos, nil
+}
+
+// DeleteUser removes the user from the account. When idProperty is set to IdPropertyEmail,
+// userId is treated as the email address of the user.
+func (c *Client) DeleteUser(ctx context.Context, userId string, idProperty string) (annotations.Annotations, error) {
+	var queryParams url.Values
+	if idProperty != "" {
+		queryParams = url.Values{}
+		queryParams.Add("idProperty", idProperty)
+	}
+
+	annos, err := c.delete(
+		ctx,
+		fmt.Sprintf(UserBaseURL, url.PathEscape(userId)),
+		queryParams,
+	)
+	if err != nil {
+		return annos, err
 	}
 
 	return annos, nil
@@ -231,7 +583,7 @@ func (c *Client) GetDeletedUsers(ctx context.Context, pageOptions GetUsersVars)
 		&res,
 	)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", annos, err
 	}
 	var ids []string
 	for _, user := range res.Results {
@@ -243,6 +595,230 @@ func (c *Client) GetDeletedUsers(ctx context.Context, pageOptions GetUsersVars)
 	return ids, "", annos, nil
 }
 
+// SearchObjectsByOwner returns a page of CRM records of the given object type owned by the CRM owner,
+// with the total of the matching records. The search index is updated asynchronously, so recently
+// updated records may still be returned with their former owner.
+func (c *Client) SearchObjectsByOwner(ctx context.Context, objectType string, ownerId string, pageOptions GetUsersVars) ([]CRMObject, int, string, annotations.Annotations, error) {
+	payload := SearchUserObjectPayload{
+		FilterGroups: []Filters{{Filters: []Filter{
+			{
+				PropertieName: HSOwnerId,
+				Operator:      EqualOperator,
+				Value:         ownerId,
+			},
+		}}},
+		Properties: []string{HSOwnerId},
+		Limit:      pageOptions.Limit,
+		After:      pageOptions.After,
+	}
+
+	var res CRMObjectsResponse
+	annos, err := c.post(
+		ctx,
+		fmt.Sprintf(CRMObjectSearchURL, url.PathEscape(objectType)),
+		payload,
+		&res,
+	)
+	if err != nil {
+		return nil, 0, "", annos, err
+	}
+
+	if (res.Paging != PaginationData{}) {
+		return res.Results, res.Total, res.Paging.Next.After, annos, nil
+	}
+
+	return res.Results, res.Total, "", annos, nil
+}
+
+// UpdateObjectsOwner assigns the CRM records of the given object type to the CRM owner.
+// At most MaxBatchSize records are updated per call. When only some of the records are updated,
+// HubSpot answers with the 207 Multi-Status code and a *BatchError listing the failed IDs is returned.
+func (c *Client) UpdateObjectsOwner(ctx context.Context, objectType string, objectIds []string, ownerId string) (annotations.Annotations, error) {
+	if len(objectIds) > MaxBatchSize {
+		return nil, fmt.Errorf("hubspot: at most %d records can be updated at once, got %d", MaxBatchSize, len(objectIds))
+	}
+
+	payload := CRMBatchPayload{
+		Inputs: make([]CRMObjectInput, 0, len(objectIds)),
+	}
+	for _, id := range objectIds {
+		payload.Inputs = append(payload.Inputs, CRMObjectInput{
+			Id:         id,
+			Properties: map[string]string{HSOwnerId: ownerId},
+		})
+	}
+
+	var res CRMBatchResponse
+	annos, err := c.post(
+		ctx,
+		fmt.Sprintf(CRMObjectBatchUpdateURL, url.PathEscape(objectType)),
+		payload,
+		&res,
+	)
+	if err != nil {
+		return annos, err
+	}
+
+	if len(res.Errors) > 0 {
+		return annos, &BatchError{Errors: res.Errors}
+	}
+
+	return annos, nil
+}
+
+// GetLoginActivity returns a page of login activity for all users of the account, newest first.
+func (c *Client) GetLoginActivity(ctx context.Context, vars GetActivityVars) ([]LoginActivity, string, annotations.Annotations, error) {
+	queryParams := setupActivityQuery(vars)
+	var accountLoginResponse AccountLoginResponse
+
+	annos, err := c.get(
+		ctx,
+		AccountLastLogin,
+		&accountLoginResponse,
+		queryParams,
+	)
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	if (accountLoginResponse.Paging != PaginationData{}) {
+		return accountLoginResponse.Results, accountLoginResponse.Paging.Next.After, annos, nil
+	}
+
+	return accountLoginResponse.Results, "", annos, nil
+}
+
+// GetAuditLogs returns a page of the account audit logs which occurred after provided time.
+func (c *Client) GetAuditLogs(ctx context.Context, vars GetActivityVars) ([]AuditLog, string, annotations.Annotations, error) {
+	queryParams := setupActivityQuery(vars)
+
+	var auditLogsResponse AuditLogsResponse
+	annos, err := c.get(
+		ctx,
+		AccountAuditLogs,
+		&auditLogsResponse,
+		queryParams,
+	)
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	if (auditLogsResponse.Paging != PaginationData{}) {
+		return auditLogsResponse.Results, auditLogsResponse.Paging.Next.After, annos, nil
+	}
+
+	return auditLogsResponse.Results, "", annos, nil
+}
+
+// GetSecurityActivity returns a page of the account security activity which occurred after provided time.
+func (c *Client) GetSecurityActivity(ctx context.Context, vars GetActivityVars) ([]SecurityActivity, string, annotations.Annotations, error) {
+	queryParams := setupActivityQuery(vars)
+
+	var securityActivityResponse SecurityActivityResponse
+	annos, err := c.get(
+		ctx,
+		AccountSecurityActivity,
+		&securityActivityResponse,
+		queryParams,
+	)
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	if (securityActivityResponse.Paging != PaginationData{}) {
+		return securityActivityResponse.Results, securityActivityResponse.Paging.Next.After, annos, nil
+	}
+
+	return securityActivityResponse.Results, "", annos, nil
+}
+
+// IsUserDeactivated reports whether the user is deactivated in the account.
+func (c *Client) IsUserDeactivated(ctx context.Context, userId string) (bool, annotations.Annotations, error) {
+	filters := []Filters{{Filters: []Filter{
+		{
+			PropertieName: HSInternalUserId,
+			Operator:      EqualOperator,
+			Value:         userId,
+		},
+		{
+			PropertieName: "hs_deactivated",
+			Operator:      EqualOperator,
+			Value:         "true",
+		},
+	}}}
+	payload := SearchUserObjectPayload{
+		FilterGroups: filters,
+		Properties:   []string{"hs_deactivated", HSInternalUserId},
+		Limit:        1,
+	}
+
+	var res SearchUserObjectResponse
+	annos, err := c.post(
+		ctx,
+		SearchUserObjectURL,
+		payload,
+		&res,
+	)
+	if err != nil {
+		return false, annos, err
+	}
+
+	return len(res.Results) > 0, annos, nil
+}
+
+// FindUserObject returns the record of the user in the CRM users object, nil when the user has none.
+// The record holds the properties of the user the settings API does not expose, such as its deactivation.
+func (c *Client) FindUserObject(ctx context.Context, userId string) (*UserObject, annotations.Annotations, error) {
+	payload := SearchUserObjectPayload{
+		FilterGroups: []Filters{{Filters: []Filter{
+			{
+				PropertieName: HSInternalUserId,
+				Operator:      EqualOperator,
+				Value:         userId,
+			},
+		}}},
+		Properties: []string{"hs_deactivated", HSInternalUserId},
+		Limit:      1,
+	}
+
+	var res SearchUserObjectResponse
+	annos, err := c.post(
+		ctx,
+		SearchUserObjectURL,
+		payload,
+		&res,
+	)
+	if err != nil {
+		return nil, annos, err
+	}
+
+	if len(res.Results) == 0 {
+		return nil, annos, nil
+	}
+
+	return &res.Results[0], annos, nil
+}
+
+// SetUserObjectDeactivated sets the hs_deactivated property of the CRM user record, a deactivated
+// user can no longer log in but keeps its settings and records.
+func (c *Client) SetUserObjectDeactivated(ctx context.Context, objectId string, deactivated bool) (annotations.Annotations, error) {
+	payload := UpdateUserObjectPayload{
+		Properties: UserObjectProperties{Deactivated: strconv.FormatBool(deactivated)},
+	}
+
+	annos, err := c.patch(
+		ctx,
+		fmt.Sprintf(UserObjectURL, url.PathEscape(objectId)),
+		payload,
+		nil,
+	)
+	if err != nil {
+		return annos, err
+	}
+
+	return annos, nil
+}
+
 func (c *Client) GetUserLastLogin(ctx context.Context, userId string) (*time.Time, annotations.Annotations, error) {
 	queryParams := setupPaginationQuery(url.Values{}, 5, "")
 	var accountLoginResponse AccountLoginResponse
@@ -267,6 +843,70 @@ func (c *Client) GetUserLastLogin(ctx context.Context, userId string) (*time.Tim
 	return nil, annos, nil
 }
 
+// TokenInfo describes the access token of the client and the private app or OAuth app it was issued for.
+// UserId is the user the OAuth app was installed by, or the user the private app token was issued to.
+type TokenInfo struct {
+	HubId      int      `json:"hub_id"`
+	AppId      int      `json:"app_id"`
+	UserId     int      `json:"user_id"`
+	Scopes     []string `json:"scopes"`
+	PrivateApp bool     `json:"-"`
+}
+
+type privateAppTokenInfo struct {
+	HubId  int      `json:"hubId"`
+	AppId  int      `json:"appId"`
+	UserId int      `json:"userId"`
+	Scopes []string `json:"scopes"`
+}
+
+type privateAppTokenInfoPayload struct {
+	TokenKey string `json:"tokenKey"`
+}
+
+// GetTokenInfo returns the scopes granted to the token used by the client and the app it was issued for,
+// from the introspection of OAuth access tokens or of private app tokens.
+func (c *Client) GetTokenInfo(ctx context.Context) (TokenInfo, annotations.Annotations, error) {
+	accessToken, err := c.token(ctx, "")
+	if err != nil {
+		return TokenInfo{}, nil, err
+	}
+
+	if c.oauth != nil {
+		var tokenInfo TokenInfo
+		annos, err := c.get(
+			ctx,
+			fmt.Sprintf(OAuthAccessTokenInfoURL, url.PathEscape(accessToken)),
+			&tokenInfo,
+			nil,
+		)
+		if err != nil {
+			return TokenInfo{}, annos, err
+		}
+
+		return tokenInfo, annos, nil
+	}
+
+	var appTokenInfo privateAppTokenInfo
+	annos, err := c.post(
+		ctx,
+		PrivateAppTokenInfoURL,
+		&privateAppTokenInfoPayload{TokenKey: accessToken},
+		&appTokenInfo,
+	)
+	if err != nil {
+		return TokenInfo{}, annos, err
+	}
+
+	return TokenInfo{
+		HubId:      appTokenInfo.HubId,
+		AppId:      appTokenInfo.AppId,
+		UserId:     appTokenInfo.UserId,
+		Scopes:     appTokenInfo.Scopes,
+		PrivateApp: true,
+	}, annos, nil
+}
+
 func (c *Client) get(ctx context.Context, url string, resourceResponse interface{}, queryParams url.Values) (annotations.Annotations, error) {
 	return c.doRequest(ctx, url, http.MethodGet, nil, resourceResponse, queryParams)
 }
@@ -275,10 +915,18 @@ func (c *Client) put(ctx context.Context, url string, data interface{}, resource
 	return c.doRequest(ctx, url, http.MethodPut, data, resourceResponse, nil)
 }
 
+func (c *Client) patch(ctx context.Context, url string, data interface{}, resourceResponse interface{}) (annotations.Annotations, error) {
+	return c.doRequest(ctx, url, http.MethodPatch, data, resourceResponse, nil)
+}
+
 func (c *Client) post(ctx context.Context, url string, data interface{}, resourceResponse interface{}) (annotations.Annotations, error) {
 	return c.doRequest(ctx, url, http.MethodPost, data, resourceResponse, nil)
 }
 
+func (c *Client) delete(ctx context.Context, url string, queryParams url.Values) (annotations.Annotations, error) {
+	return c.doRequest(ctx, url, http.MethodDelete, nil, nil, queryParams)
+}
+
 func (c *Client) doRequest(
 	ctx context.Context,
 	urlAddress string,
@@ -287,43 +935,57 @@ func (c *Client) doRequest(
 	resourceResponse interface{},
 	queryParams url.Values,
 ) (annotations.Annotations, error) {
-	var body io.Reader
+	var jsonBody []byte
 
 	if data != nil {
-		jsonBody, err := json.Marshal(data)
+		var err error
+		jsonBody, err = json.Marshal(data)
 		if err != nil {
 			return nil, err
 		}
-
-		body = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, urlAddress, body)
+	accessToken, err := c.token(ctx, "")
 	if err != nil {
 		return nil, err
 	}
 
-	if queryParams != nil {
-		req.URL.RawQuery = queryParams.Encode()
+	rawResponse, err := c.send(ctx, urlAddress, method, jsonBody, queryParams, accessToken)
+	if err != nil {
+		return nil, err
 	}
 
-	req.Header.Add("Authorization", fmt.Sprint("Bearer ", c.accessToken))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
+	// OAuth access token could be revoked before its expiry, retry once with a refreshed one
+	if rawResponse.StatusCode == http.StatusUnauthorized && c.oauth != nil {
+		rawResponse.Body.Close()
 
-	rawResponse, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+		accessToken, err = c.token(ctx, accessToken)
+		if err != nil {
+			return nil, err
+		}
+
+		rawResponse, err = c.send(ctx, urlAddress, method, jsonBody, queryParams, accessToken)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	defer rawResponse.Body.Close()
 
 	if rawResponse.StatusCode >= 300 {
-		return nil, status.Error(codes.Code(rawResponse.StatusCode), "Request failed") //nolint:gosec // safe conversion: HTTP status code is always in range 0-599
+		// rate limit data is best effort on failed responses, the API error takes precedence
+		annos := annotations.Annotations{}
+		if rateLimitData, err := extractRateLimitData(rawResponse); err == nil {
+			annos.WithRateLimiting(rateLimitData)
+		}
+
+		return annos, newAPIError(rawResponse)
 	}
 
-	if err := json.NewDecoder(rawResponse.Body).Decode(&resourceResponse); err != nil {
-		return nil, err
+	if resourceResponse != nil {
+		if err := json.NewDecoder(rawResponse.Body).Decode(&resourceResponse); err != nil {
+			return nil, err
+		}
 	}
 
 	rateLimitData, err := extractRateLimitData(rawResponse)
@@ -337,6 +999,35 @@ func (c *Client) doRequest(
 	return annos, nil
 }
 
+func (c *Client) send(
+	ctx context.Context,
+	urlAddress string,
+	method string,
+	jsonBody []byte,
+	queryParams url.Values,
+	accessToken string,
+) (*http.Response, error) {
+	var body io.Reader
+	if jsonBody != nil {
+		body = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint(urlAddress), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if queryParams != nil {
+		req.URL.RawQuery = queryParams.Encode()
+	}
+
+	req.Header.Add("Authorization", fmt.Sprint("Bearer ", accessToken))
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+
+	return c.httpClient.Do(req)
+}
+
 // extractRateLimitData returns a set of annotations for rate limiting given the rate limit headers provided by HubSpot.
 func extractRateLimitData(response *http.Response) (*v2.RateLimitDescription, error) {
 	if response == nil {
@@ -0,0 +1,291 @@
+package hubspot_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "https://api.hubapi.com", want: "https://api.hubapi.com/"},
+		{baseURL: "https://api-eu1.hubapi.com/", want: "https://api-eu1.hubapi.com/"},
+		{baseURL: "http://localhost:8080/hubspot", want: "http://localhost:8080/hubspot/"},
+		{baseURL: "api.hubapi.com", wantErr: true},
+		{baseURL: "ftp://api.hubapi.com", wantErr: true},
+		{baseURL: "https://api.hubapi.com/?debug=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := hubspot.ParseBaseURL(tt.baseURL)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseBaseURL(%q): unexpected error %v", tt.baseURL, err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseBaseURL(%q) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+}
+
+func TestClientUsers(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	client := s.Client()
+
+	created, _, err := client.CreateUser(ctx, &hubspot.CreateUserPayload{Email: "jane@example.com", FirstName: "Jane"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, _, err := client.GetUser(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "jane@example.com" || user.FirstName != "Jane" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	_, _, err = client.CreateUser(ctx, &hubspot.CreateUserPayload{Email: "jane@example.com"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists for a duplicate email, got %v", err)
+	}
+
+	if _, err := client.DeleteUser(ctx, created.Id, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = client.GetUser(ctx, created.Id)
+	if !hubspot.IsNotFound(err) || status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a deleted user, got %v", err)
+	}
+}
+
+func TestUpdateUserPayload(t *testing.T) {
+	tests := []struct {
+		payload hubspot.UpdateUserPayload
+		want    string
+	}{
+		{
+			payload: hubspot.UpdateUserPayload{SecondaryTeamIDs: []string{}},
+			want:    `{"secondaryTeamIds":[]}`,
+		},
+		{
+			payload: hubspot.UpdateUserPayload{RoleId: "1", PrimaryTeamId: "2", SecondaryTeamIDs: []string{"3"}},
+			want:    `{"secondaryTeamIds":["3"],"roleId":"1","primaryTeamId":"2"}`,
+		},
+		{
+			payload: hubspot.UpdateUserPayload{RoleId: "1", PrimaryTeamId: "2", SecondaryTeamIDs: []string{}, ClearRole: true},
+			want:    `{"secondaryTeamIds":[],"roleId":"","primaryTeamId":"2"}`,
+		},
+		{
+			payload: hubspot.UpdateUserPayload{RoleId: "1", SecondaryTeamIDs: []string{}, ClearPrimaryTeam: true},
+			want:    `{"secondaryTeamIds":[],"roleId":"1","primaryTeamId":""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := json.Marshal(&tt.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.InjectFailure(hubspottest.Failure{
+		Path:       "/" + hubspot.UsersBaseURL,
+		StatusCode: http.StatusForbidden,
+		Category:   "MISSING_SCOPES",
+		Message:    "This app hasn't been granted all required scopes.",
+	})
+
+	_, _, _, err := s.Client().GetUsers(ctx, hubspot.GetUsersVars{})
+	if !hubspot.IsForbidden(err) || status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+	if want := "hubspot: request failed with status 403 (MISSING_SCOPES): This app hasn't been granted all required scopes."; !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestOAuthClientRefreshesAccessToken(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetOAuthApp(hubspottest.OAuthApp{
+		ClientId:           "client",
+		ClientSecret:       "secret",
+		RefreshToken:       "refresh",
+		RotateRefreshToken: true,
+	})
+	s.SetScopes("settings.users.read")
+	s.SetApp(42, 7)
+
+	client := hubspot.NewOAuthClient(hubspot.OAuthCredentials{
+		ClientId:     "client",
+		ClientSecret: "secret",
+		RefreshToken: "refresh",
+	}, s.Server.Client(), hubspot.WithBaseURL(s.BaseURL()))
+
+	info, _, err := client.GetTokenInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.HubId != hubspottest.DefaultPortalId || len(info.Scopes) != 1 || info.AppId != 42 || info.UserId != 7 || info.PrivateApp {
+		t.Fatalf("unexpected token info %+v", info)
+	}
+
+	// a revoked access token is replaced using the rotated refresh token
+	s.SetToken("revoked")
+	if _, _, _, err := client.GetUsers(ctx, hubspot.GetUsersVars{}); err != nil {
+		t.Fatalf("expected the request to be retried with a new access token, got %v", err)
+	}
+}
+
+func TestClientPrivateAppTokenInfo(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetScopes("settings.users.read", "settings.users.write")
+	s.SetApp(42, 7)
+
+	info, _, err := s.Client().GetTokenInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.HubId != hubspottest.DefaultPortalId || len(info.Scopes) != 2 || info.AppId != 42 || info.UserId != 7 || !info.PrivateApp {
+		t.Fatalf("unexpected token info %+v", info)
+	}
+}
+
+func TestClientActivityOccurredAfter(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		s.AddAuditLog(hubspot.AuditLog{Id: string(rune('a' + i)), Action: "UPDATE", OccurredAt: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	auditLogs, _, _, err := s.Client().GetAuditLogs(ctx, hubspot.GetActivityVars{OccurredAfter: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auditLogs) != 2 || auditLogs[0].Id != "c" {
+		t.Fatalf("expected the two later entries newest first, got %+v", auditLogs)
+	}
+}
+
+func TestClientOwnerByUserId(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.AddOwner(hubspot.Owner{Email: "active@example.com", UserId: 1})
+	archived := s.AddOwner(hubspot.Owner{Email: "archived@example.com", UserId: 2, Archived: true})
+
+	owner, _, err := s.Client().GetOwnerByUserId(ctx, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.Id != archived.Id {
+		t.Fatalf("expected the archived owner, got %+v", owner)
+	}
+
+	_, _, err = s.Client().GetOwnerByUserId(ctx, "3")
+	if !hubspot.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestClientTransfersObjects(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	for range 3 {
+		s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "1"}})
+	}
+	s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "2"}})
+
+	client := s.Client()
+	deals, total, _, _, err := client.SearchObjectsByOwner(ctx, "deals", "1", hubspot.GetUsersVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deals) != 3 || total != 3 {
+		t.Fatalf("expected 3 deals, got %d of %d", len(deals), total)
+	}
+
+	var ids []string
+	for _, deal := range deals {
+		ids = append(ids, deal.Id)
+	}
+	if _, err := client.UpdateObjectsOwner(ctx, "deals", ids, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, deal := range s.Objects("deals") {
+		if deal.Properties[hubspot.HSOwnerId] != "2" {
+			t.Fatalf("expected deal %s to be transferred", deal.Id)
+		}
+	}
+}
+
+func TestClientReportsFailedObjects(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	deal := s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "1"}})
+	locked := s.AddObject("deals", hubspot.CRMObject{Properties: map[string]string{hubspot.HSOwnerId: "1"}})
+	s.LockObjects("deals", locked.Id)
+
+	_, err := s.Client().UpdateObjectsOwner(ctx, "deals", []string{deal.Id, locked.Id, "missing"}, "2")
+
+	var batchErr *hubspot.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a batch error, got %v", err)
+	}
+	failed := batchErr.FailedIds()
+	slices.Sort(failed)
+	if want := []string{locked.Id, "missing"}; !slices.Equal(failed, want) {
+		t.Fatalf("got failed IDs %v, want %v", failed, want)
+	}
+
+	for _, object := range s.Objects("deals") {
+		want := "2"
+		if object.Id == locked.Id {
+			want = "1"
+		}
+		if owner := object.Properties[hubspot.HSOwnerId]; owner != want {
+			t.Fatalf("expected deal %s to be owned by %s, got %s", object.Id, want, owner)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package hubspot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// APIError is returned for HubSpot API responses with a non successful status code.
+type APIError struct {
+	StatusCode    int    `json:"-"`
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+	Category      string `json:"category"`
+	CorrelationId string `json:"correlationId"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("hubspot: request failed with status %d", e.StatusCode)
+	if e.Category != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Category)
+	}
+	if e.Message != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Message)
+	}
+	if e.CorrelationId != "" {
+		msg = fmt.Sprintf("%s [correlationId: %s]", msg, e.CorrelationId)
+	}
+
+	return msg
+}
+
+// GRPCStatus maps the HTTP status code of the error to a gRPC status,
+// so that the error is recognized by status.FromError and status.Code.
+func (e *APIError) GRPCStatus() *status.Status {
+	return status.New(httpStatusToCode(e.StatusCode), e.Error())
+}
+
+// BatchError is returned for CRM batch requests where some of the inputs failed.
+type BatchError struct {
+	Errors []CRMBatchError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, batchErr := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", batchErr.Category, batchErr.Message))
+	}
+
+	return fmt.Sprintf("hubspot: %d inputs of the batch failed: %s", len(e.FailedIds()), strings.Join(messages, "; "))
+}
+
+// FailedIds returns the IDs of the inputs that failed.
+func (e *BatchError) FailedIds() []string {
+	var ids []string
+	for _, batchErr := range e.Errors {
+		ids = append(ids, batchErr.Context["ids"]...)
+	}
+
+	return ids
+}
+
+// IsNotFound reports whether err is an APIError for a missing resource.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusNotFound
+	}
+
+	return false
+}
+
+func httpStatusToCode(statusCode int) codes.Code {
+	switch {
+	case statusCode == http.StatusBadRequest:
+		return codes.InvalidArgument
+	case statusCode == http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case statusCode == http.StatusForbidden:
+		return codes.PermissionDenied
+	case statusCode == http.StatusNotFound:
+		return codes.NotFound
+	case statusCode == http.StatusConflict:
+		return codes.AlreadyExists
+	case statusCode == http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case statusCode == http.StatusNotImplemented:
+		return codes.Unimplemented
+	case statusCode >= http.StatusInternalServerError:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// newAPIError reads the HubSpot JSON error body from the response.
+func newAPIError(response *http.Response) *APIError {
+	apiErr := &APIError{}
+
+	body, err := io.ReadAll(response.Body)
+	if err == nil && len(body) > 0 {
+		if err := json.Unmarshal(body, apiErr); err != nil {
+			apiErr.Message = string(body)
+		}
+	}
+
+	apiErr.StatusCode = response.StatusCode
+
+	return apiErr
+}
+
+// IsForbidden reports whether err is an APIError for a request the token is not allowed to make,
+// which is also the case for features not available in the account.
+func IsForbidden(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusForbidden
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package hubspottest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+)
+
+// occurredAfter parses the occurredAfter query parameter of the activity endpoints,
+// writing the error response when it is invalid.
+func occurredAfter(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	value := r.URL.Query().Get("occurredAfter")
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	occurredAfter, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid occurredAfter.")
+		return time.Time{}, false
+	}
+
+	return occurredAfter, true
+}
+
+// listLoginActivity serves the logins newest first, filtered by user and by the time they occurred.
+func (s *Server) listLoginActivity(w http.ResponseWriter, r *http.Request) {
+	after, ok := occurredAfter(w, r)
+	if !ok {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	userId := r.URL.Query().Get("userId")
+
+	var logins []hubspot.LoginActivity
+	for _, login := range s.logins {
+		if userId != "" && login.UserId != userId {
+			continue
+		}
+		if !login.LoginAt.After(after) {
+			continue
+		}
+		logins = append(logins, login)
+	}
+
+	start, end, paging := queryPage(r, len(logins))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: logins[start:end], Paging: paging})
+}
+
+// listAuditLogs serves the audit log entries newest first, filtered by the time they occurred.
+func (s *Server) listAuditLogs(w http.ResponseWriter, r *http.Request) {
+	after, ok := occurredAfter(w, r)
+	if !ok {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var auditLogs []hubspot.AuditLog
+	for _, auditLog := range s.auditLogs {
+		if auditLog.OccurredAt.After(after) {
+			auditLogs = append(auditLogs, auditLog)
+		}
+	}
+
+	start, end, paging := queryPage(r, len(auditLogs))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: auditLogs[start:end], Paging: paging})
+}
+
+// listSecurityActivity serves the security events newest first, filtered by the time they occurred.
+func (s *Server) listSecurityActivity(w http.ResponseWriter, r *http.Request) {
+	after, ok := occurredAfter(w, r)
+	if !ok {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var activity []hubspot.SecurityActivity
+	for _, event := range s.securityActivity {
+		if event.CreatedAt.After(after) {
+			activity = append(activity, event)
+		}
+	}
+
+	start, end, paging := queryPage(r, len(activity))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: activity[start:end], Paging: paging})
+}
@@ -0,0 +1,277 @@
+package hubspottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+)
+
+// searchUsers serves the CRM users search, supporting EQ filters on the internal user ID
+// and the deactivated flag. Filters of a group must all match, any group may match.
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	var payload hubspot.SearchUserObjectPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body.")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var results []hubspot.UserObject
+	for _, user := range s.users {
+		object := hubspot.UserObject{
+			BaseResource: hubspot.BaseResource{Id: "crm-" + user.Id},
+			Properties: hubspot.UserObjectProperties{
+				UserId:      user.Id,
+				Deactivated: strconv.FormatBool(s.deactivated[user.Id]),
+			},
+		}
+		properties := map[string]string{
+			hubspot.HSInternalUserId: object.Properties.UserId,
+			"hs_deactivated":         object.Properties.Deactivated,
+		}
+		if matchesFilterGroups(properties, payload.FilterGroups) {
+			results = append(results, object)
+		}
+	}
+
+	start, end, paging := page(payload.Limit, payload.After, len(results))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: results[start:end], Paging: paging})
+}
+
+// updateUserObject serves the update of the CRM user record of a user, supporting the deactivated flag.
+func (s *Server) updateUserObject(w http.ResponseWriter, r *http.Request) {
+	var payload hubspot.UpdateUserObjectPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body.")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	userId, ok := strings.CutPrefix(r.PathValue("objectId"), "crm-")
+	if !ok || s.userIndex(userId) < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "Object not found.")
+		return
+	}
+
+	if payload.Properties.Deactivated != "" {
+		deactivated, err := strconv.ParseBool(payload.Properties.Deactivated)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid hs_deactivated value.")
+			return
+		}
+		s.deactivated[userId] = deactivated
+	}
+
+	writeJSON(w, http.StatusOK, hubspot.UserObject{
+		BaseResource: hubspot.BaseResource{Id: "crm-" + userId},
+		Properties: hubspot.UserObjectProperties{
+			UserId:      userId,
+			Deactivated: strconv.FormatBool(s.deactivated[userId]),
+		},
+	})
+}
+
+// searchResponse is the response of the CRM searches, with the total of the matching records.
+type searchResponse struct {
+	Total   int                     `json:"total"`
+	Results interface{}             `json:"results"`
+	Paging  *hubspot.PaginationData `json:"paging,omitempty"`
+}
+
+// cloneObjects returns a deep copy of the CRM records.
+func cloneObjects(objects []hubspot.CRMObject) []hubspot.CRMObject {
+	rv := make([]hubspot.CRMObject, 0, len(objects))
+	for _, object := range objects {
+		object.Properties = maps.Clone(object.Properties)
+		rv = append(rv, object)
+	}
+
+	return rv
+}
+
+// searchObjects serves the search of the CRM records of an object type, supporting EQ filters on their properties.
+func (s *Server) searchObjects(w http.ResponseWriter, r *http.Request) {
+	var payload hubspot.SearchUserObjectPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body.")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objectType := r.PathValue("objectType")
+	objects := s.objects[objectType]
+	if s.staleSearches[objectType] > 0 {
+		objects = s.searchIndex[objectType]
+		s.staleSearches[objectType]--
+	}
+
+	results := []hubspot.CRMObject{}
+	for _, object := range objects {
+		if matchesFilterGroups(object.Properties, payload.FilterGroups) {
+			results = append(results, object)
+		}
+	}
+
+	start, end, paging := page(payload.Limit, payload.After, len(results))
+	writeJSON(w, http.StatusOK, searchResponse{Total: len(results), Results: results[start:end], Paging: paging})
+}
+
+// batchUpdateObjects serves the batch update of CRM records. Inputs of records that do not exist or are locked fail,
+// the response then has the 207 Multi-Status code and lists the failed IDs, like HubSpot does.
+func (s *Server) batchUpdateObjects(w http.ResponseWriter, r *http.Request) {
+	var payload hubspot.CRMBatchPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body.")
+		return
+	}
+
+	if len(payload.Inputs) > hubspot.MaxBatchSize {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Batch size must not exceed %d.", hubspot.MaxBatchSize))
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objectType := r.PathValue("objectType")
+	objects := s.objects[objectType]
+	if s.searchLag > 0 {
+		// searches keep returning the records as they were before the first of the lagging updates
+		if s.staleSearches[objectType] == 0 {
+			s.searchIndex[objectType] = cloneObjects(objects)
+		}
+		s.staleSearches[objectType] = s.searchLag
+	}
+	response := hubspot.CRMBatchResponse{Status: "COMPLETE", Results: []hubspot.CRMObject{}}
+
+	var missing, locked []string
+	for _, input := range payload.Inputs {
+		i := slices.IndexFunc(objects, func(object hubspot.CRMObject) bool { return object.Id == input.Id })
+		if i < 0 {
+			missing = append(missing, input.Id)
+			continue
+		}
+		if s.lockedObjects[objectType+"/"+input.Id] {
+			locked = append(locked, input.Id)
+			continue
+		}
+
+		if objects[i].Properties == nil {
+			objects[i].Properties = make(map[string]string)
+		}
+		for name, value := range input.Properties {
+			objects[i].Properties[name] = value
+		}
+		response.Results = append(response.Results, objects[i])
+	}
+
+	if len(missing) > 0 {
+		response.Errors = append(response.Errors, hubspot.CRMBatchError{
+			Status:   "error",
+			Category: "OBJECT_NOT_FOUND",
+			Message:  "Could not get some objects, they may be deleted or not exist.",
+			Context:  map[string][]string{"ids": missing},
+		})
+	}
+	if len(locked) > 0 {
+		response.Errors = append(response.Errors, hubspot.CRMBatchError{
+			Status:   "error",
+			Category: "VALIDATION_ERROR",
+			Message:  "Some objects could not be updated.",
+			Context:  map[string][]string{"ids": locked},
+		})
+	}
+
+	if len(response.Errors) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	response.NumErrors = len(response.Errors)
+	writeJSON(w, http.StatusMultiStatus, response)
+}
+
+// listOwners serves the CRM owners, only the archived ones when requested.
+func (s *Server) listOwners(w http.ResponseWriter, r *http.Request) {
+	archived := r.URL.Query().Get("archived") == "true"
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var owners []hubspot.Owner
+	for _, owner := range s.owners {
+		if owner.Archived == archived {
+			owners = append(owners, owner)
+		}
+	}
+
+	start, end, paging := queryPage(r, len(owners))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: owners[start:end], Paging: paging})
+}
+
+// getOwner serves a CRM owner by ID or, with the userId ID property, by the ID of its user.
+// Archived owners are only found when requested.
+func (s *Server) getOwner(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	archived := query.Get("archived") == "true"
+	byUserId := query.Get("idProperty") == hubspot.IdPropertyUserId
+	id := r.PathValue("id")
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, owner := range s.owners {
+		if owner.Archived != archived {
+			continue
+		}
+		if (byUserId && strconv.Itoa(owner.UserId) == id) || (!byUserId && owner.Id == id) {
+			writeJSON(w, http.StatusOK, owner)
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "Owner not found.")
+}
+
+func matchesFilterGroups(properties map[string]string, groups []hubspot.Filters) bool {
+	if len(groups) == 0 {
+		return true
+	}
+
+	for _, group := range groups {
+		matches := true
+		for _, filter := range group.Filters {
+			if !matchesFilter(properties, filter) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesFilter(properties map[string]string, filter hubspot.Filter) bool {
+	if filter.Operator != hubspot.EqualOperator {
+		return false
+	}
+
+	value, ok := properties[filter.PropertieName]
+
+	return ok && value == filter.Value
+}
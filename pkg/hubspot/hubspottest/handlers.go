@@ -0,0 +1,317 @@
+package hubspottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+)
+
+type pagedResponse struct {
+	Results interface{}             `json:"results"`
+	Paging  *hubspot.PaginationData `json:"paging,omitempty"`
+}
+
+// page returns the bounds of the page requested with the limit and after parameters,
+// after being the offset of the first result like in the HubSpot API.
+func page(limit int, after string, total int) (int, int, *hubspot.PaginationData) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	start, err := strconv.Atoi(after)
+	if err != nil || start < 0 {
+		start = 0
+	}
+	start = min(start, total)
+	end := min(start+limit, total)
+
+	if end >= total {
+		return start, end, nil
+	}
+
+	return start, end, &hubspot.PaginationData{Next: hubspot.Page{After: strconv.Itoa(end)}}
+}
+
+func queryPage(r *http.Request, total int) (int, int, *hubspot.PaginationData) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	return page(limit, r.URL.Query().Get("after"), total)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	start, end, paging := queryPage(r, len(s.users))
+	writeJSON(w, http.StatusOK, pagedResponse{Results: s.users[start:end], Paging: paging})
+}
+
+// lookupUser returns the index of the user addressed by the request, either by ID or by email.
+func (s *Server) lookupUser(r *http.Request) int {
+	if r.URL.Query().Get("idProperty") == hubspot.IdPropertyEmail {
+		return s.userIndexByEmail(r.PathValue("id"))
+	}
+
+	return s.userIndex(r.PathValue("id"))
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.lookupUser(r)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "User not found.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.users[i])
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var payload hubspot.CreateUserPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Email == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "A valid email is required.")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.userIndexByEmail(payload.Email) >= 0 {
+		writeError(w, http.StatusConflict, "CONFLICT", "A user with this email already exists.")
+		return
+	}
+
+	user := hubspot.User{
+		BaseResource: hubspot.BaseResource{Id: s.newId()},
+		Email:        payload.Email,
+		FirstName:    payload.FirstName,
+		LastName:     payload.LastName,
+		TeamId:       payload.PrimaryTeamId,
+	}
+	if payload.RoleId != "" {
+		user.RoleIDs = []string{payload.RoleId}
+	}
+	s.users = append(s.users, user)
+	if payload.SendWelcomeEmail {
+		s.welcomeEmails = append(s.welcomeEmails, user.Email)
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// updateUser changes the role, the teams and the super admin flag of the user like the HubSpot API does:
+// fields omitted from the payload are left unchanged, empty values clear them.
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body.")
+		return
+	}
+
+	var payload hubspot.UpdateUserPayload
+	for name, value := range fields {
+		var err error
+		switch name {
+		case "roleId":
+			err = json.Unmarshal(value, &payload.RoleId)
+		case "primaryTeamId":
+			err = json.Unmarshal(value, &payload.PrimaryTeamId)
+		case "secondaryTeamIds":
+			err = json.Unmarshal(value, &payload.SecondaryTeamIDs)
+		case "superAdmin":
+			err = json.Unmarshal(value, &payload.SuperAdmin)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Invalid %s.", name))
+			return
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.lookupUser(r)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "User not found.")
+		return
+	}
+
+	user := &s.users[i]
+	if _, ok := fields["roleId"]; ok {
+		user.RoleIDs = nil
+		if payload.RoleId != "" {
+			user.RoleIDs = []string{payload.RoleId}
+		}
+	}
+	if _, ok := fields["primaryTeamId"]; ok {
+		user.TeamId = payload.PrimaryTeamId
+	}
+	if _, ok := fields["secondaryTeamIds"]; ok {
+		user.SecondaryTeamIDs = payload.SecondaryTeamIDs
+	}
+	if payload.SuperAdmin != nil {
+		user.SuperAdmin = *payload.SuperAdmin
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	i := s.lookupUser(r)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "User not found.")
+		return
+	}
+
+	delete(s.deactivated, s.users[i].Id)
+	s.users = slices.Delete(s.users, i, i+1)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// teamWithMembers fills the members of the team and its child teams from the users.
+func (s *Server) teamWithMembers(team hubspot.Team) hubspot.Team {
+	team.UserIDs = nil
+	team.SecondaryUserIDs = nil
+	for _, user := range s.users {
+		if user.TeamId == team.Id {
+			team.UserIDs = append(team.UserIDs, user.Id)
+		}
+		if slices.Contains(user.SecondaryTeamIDs, team.Id) {
+			team.SecondaryUserIDs = append(team.SecondaryUserIDs, user.Id)
+		}
+	}
+
+	children := make([]hubspot.Team, 0, len(team.ChildTeams))
+	for _, child := range team.ChildTeams {
+		children = append(children, s.teamWithMembers(child))
+	}
+	team.ChildTeams = children
+
+	return team
+}
+
+func (s *Server) listTeams(w http.ResponseWriter, _ *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	teams := make([]hubspot.Team, 0, len(s.teams))
+	for _, team := range s.teams {
+		teams = append(teams, s.teamWithMembers(team))
+	}
+
+	writeJSON(w, http.StatusOK, hubspot.TeamsResponse{Results: teams})
+}
+
+func (s *Server) listRoles(w http.ResponseWriter, _ *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	writeJSON(w, http.StatusOK, hubspot.RolesResponse{Results: slices.Clone(s.roles)})
+}
+
+// listUserBusinessUnits serves the business units the user is a member of.
+func (s *Server) listUserBusinessUnits(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	userId := r.PathValue("userId")
+	if s.userIndex(userId) < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "User not found.")
+		return
+	}
+
+	businessUnits := []hubspot.BusinessUnit{}
+	for _, businessUnit := range s.businessUnits {
+		if slices.Contains(s.businessUnitUsers[businessUnit.Id], userId) {
+			businessUnits = append(businessUnits, businessUnit)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, hubspot.BusinessUnitsResponse{Results: businessUnits})
+}
+
+// businessUnitMembership returns the business unit and the user addressed by the request, writing
+// the error response when either one does not exist.
+func (s *Server) businessUnitMembership(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	businessUnitId := r.PathValue("id")
+	if !slices.ContainsFunc(s.businessUnits, func(businessUnit hubspot.BusinessUnit) bool { return businessUnit.Id == businessUnitId }) {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "Business unit not found.")
+		return "", "", false
+	}
+
+	userId := r.PathValue("userId")
+	if s.userIndex(userId) < 0 {
+		writeError(w, http.StatusNotFound, "OBJECT_NOT_FOUND", "User not found.")
+		return "", "", false
+	}
+
+	return businessUnitId, userId, true
+}
+
+func (s *Server) addBusinessUnitUser(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	businessUnitId, userId, ok := s.businessUnitMembership(w, r)
+	if !ok {
+		return
+	}
+
+	if !slices.Contains(s.businessUnitUsers[businessUnitId], userId) {
+		s.businessUnitUsers[businessUnitId] = append(s.businessUnitUsers[businessUnitId], userId)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeBusinessUnitUser(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	businessUnitId, userId, ok := s.businessUnitMembership(w, r)
+	if !ok {
+		return
+	}
+
+	s.businessUnitUsers[businessUnitId] = slices.DeleteFunc(s.businessUnitUsers[businessUnitId], func(id string) bool { return id == userId })
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, _ *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	writeJSON(w, http.StatusOK, s.account)
+}
+
+func (s *Server) listSandboxes(w http.ResponseWriter, _ *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	writeJSON(w, http.StatusOK, hubspot.SandboxesResponse{Results: slices.Clone(s.sandboxes)})
+}
+
+type tokenInfoResponse struct {
+	HubId  int      `json:"hubId"`
+	AppId  int      `json:"appId,omitempty"`
+	UserId int      `json:"userId,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+func (s *Server) getTokenInfo(w http.ResponseWriter, _ *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	writeJSON(w, http.StatusOK, tokenInfoResponse{HubId: s.account.Id, AppId: s.appId, UserId: s.appUserId, Scopes: s.scopes})
+}
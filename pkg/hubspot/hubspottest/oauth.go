@@ -0,0 +1,92 @@
+package hubspottest
+
+import (
+	"net/http"
+	"time"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+}
+
+type accessTokenInfoResponse struct {
+	Token     string   `json:"token"`
+	HubId     int      `json:"hub_id"`
+	AppId     int      `json:"app_id,omitempty"`
+	UserId    int      `json:"user_id,omitempty"`
+	Scopes    []string `json:"scopes"`
+	TokenType string   `json:"token_type"`
+	ExpiresIn int64    `json:"expires_in"`
+}
+
+// issueToken serves the exchange of the refresh token of the OAuth app for a new access token,
+// which then replaces the access token accepted by the server.
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "Invalid form.")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	app := s.oauthApp
+	switch {
+	case app == nil:
+		writeError(w, http.StatusBadRequest, "BAD_CLIENT_ID", "missing or unknown client id")
+		return
+	case r.PostForm.Get("grant_type") != "refresh_token":
+		writeError(w, http.StatusBadRequest, "UNSUPPORTED_GRANT_TYPE", "unsupported grant type")
+		return
+	case r.PostForm.Get("client_id") != app.ClientId:
+		writeError(w, http.StatusBadRequest, "BAD_CLIENT_ID", "missing or unknown client id")
+		return
+	case r.PostForm.Get("client_secret") != app.ClientSecret:
+		writeError(w, http.StatusBadRequest, "BAD_CLIENT_SECRET", "missing or invalid client secret")
+		return
+	case r.PostForm.Get("refresh_token") != app.RefreshToken:
+		writeError(w, http.StatusBadRequest, "BAD_REFRESH_TOKEN", "missing or unknown refresh token")
+		return
+	}
+
+	s.token = "hubspottest-oauth-" + s.newId()
+	if app.RotateRefreshToken {
+		app.RefreshToken = "hubspottest-refresh-" + s.newId()
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  s.token,
+		RefreshToken: app.RefreshToken,
+		ExpiresIn:    int64(app.AccessTokenTTL / time.Second),
+		TokenType:    "bearer",
+	})
+}
+
+// getAccessTokenInfo serves the metadata of the OAuth access token accepted by the server.
+func (s *Server) getAccessTokenInfo(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if r.PathValue("token") != s.token {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Token not found.")
+		return
+	}
+
+	var expiresIn int64
+	if s.oauthApp != nil {
+		expiresIn = int64(s.oauthApp.AccessTokenTTL / time.Second)
+	}
+
+	writeJSON(w, http.StatusOK, accessTokenInfoResponse{
+		Token:     s.token,
+		HubId:     s.account.Id,
+		AppId:     s.appId,
+		UserId:    s.appUserId,
+		Scopes:    s.scopes,
+		TokenType: "access",
+		ExpiresIn: expiresIn,
+	})
+}
@@ -0,0 +1,623 @@
+// Package hubspottest provides an in-memory fake of the HubSpot API for exercising
+// the HubSpot client and the connector without network access.
+package hubspottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+)
+
+const (
+	// DefaultToken is the access token accepted by the server unless another one is set.
+	DefaultToken = "hubspottest-token"
+	// DefaultPortalId is the ID of the account served by the server.
+	DefaultPortalId = 12345
+	// DefaultAccessTokenTTL is the lifetime of the access tokens issued to OAuth apps.
+	DefaultAccessTokenTTL = 30 * time.Minute
+
+	defaultPageSize          = 100
+	defaultRateLimitMax      = 190
+	defaultRateLimitInterval = 10 * time.Second
+)
+
+// Failure is an error response returned instead of the regular response of matching requests.
+type Failure struct {
+	// Method of the failing requests, requests of any method fail when empty.
+	Method string
+	// Path of the failing requests, such as /settings/v3/users.
+	Path       string
+	StatusCode int
+	Category   string
+	Message    string
+	// Times is the number of requests failing, a single one when zero.
+	Times int
+	// RetryAfter is sent in the Retry-After header when set.
+	RetryAfter time.Duration
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+}
+
+// OAuthApp is the OAuth app allowed to exchange its refresh token for access tokens.
+type OAuthApp struct {
+	ClientId     string
+	ClientSecret string
+	RefreshToken string
+	// RotateRefreshToken makes every exchange return a new refresh token, invalidating the previous one.
+	RotateRefreshToken bool
+	// AccessTokenTTL is the lifetime of the issued access tokens, DefaultAccessTokenTTL when zero.
+	AccessTokenTTL time.Duration
+}
+
+// Server is a fake HubSpot API keeping the users, teams, roles, business units, CRM owners
+// and records, sandboxes and account activity in memory. Requests are served from the URL
+// of the server, see Client to get a client using it. Requests to endpoints the server does not
+// emulate fail with 501 Not Implemented.
+type Server struct {
+	*httptest.Server
+
+	mtx               sync.Mutex
+	token             string
+	oauthApp          *OAuthApp
+	account           hubspot.Account
+	scopes            []string
+	users             []hubspot.User
+	teams             []hubspot.Team
+	roles             []hubspot.Role
+	businessUnits     []hubspot.BusinessUnit
+	businessUnitUsers map[string][]string
+	owners            []hubspot.Owner
+	objects           map[string][]hubspot.CRMObject
+	lockedObjects     map[string]bool
+	searchLag         int
+	staleSearches     map[string]int
+	searchIndex       map[string][]hubspot.CRMObject
+	sandboxes         []hubspot.Sandbox
+	appId             int
+	appUserId         int
+	deactivated       map[string]bool
+	welcomeEmails     []string
+	logins            []hubspot.LoginActivity
+	auditLogs         []hubspot.AuditLog
+	securityActivity  []hubspot.SecurityActivity
+	failures          []*Failure
+	requests          []Request
+	nextId            int
+
+	rateLimitMax      int
+	rateLimitInterval time.Duration
+	rateLimitUsed     int
+	rateLimitWindow   time.Time
+}
+
+// NewServer starts a fake HubSpot API with an empty account, it must be closed with Close.
+func NewServer() *Server {
+	s := &Server{
+		token:             DefaultToken,
+		account:           hubspot.Account{Id: DefaultPortalId, Type: "STANDARD"},
+		deactivated:       make(map[string]bool),
+		businessUnitUsers: make(map[string][]string),
+		objects:           make(map[string][]hubspot.CRMObject),
+		lockedObjects:     make(map[string]bool),
+		staleSearches:     make(map[string]int),
+		searchIndex:       make(map[string][]hubspot.CRMObject),
+		nextId:            1000,
+		rateLimitMax:      defaultRateLimitMax,
+		rateLimitInterval: defaultRateLimitInterval,
+	}
+	s.Server = httptest.NewServer(s.handler())
+
+	return s
+}
+
+// Client returns a HubSpot client sending its requests to the server.
+func (s *Server) Client() *hubspot.Client {
+	return hubspot.NewClient(s.Token(), s.Server.Client(), hubspot.WithBaseURL(s.BaseURL()))
+}
+
+// BaseURL returns the base URL to configure HubSpot clients with.
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// Token returns the access token accepted by the server.
+func (s *Server) Token() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.token
+}
+
+// SetToken changes the access token accepted by the server, other tokens are rejected.
+func (s *Server) SetToken(token string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.token = token
+}
+
+// SetOAuthApp allows the OAuth app to obtain access tokens from the token endpoint,
+// the access token accepted by the server is then the last one issued.
+func (s *Server) SetOAuthApp(app OAuthApp) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if app.AccessTokenTTL == 0 {
+		app.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	s.oauthApp = &app
+}
+
+// RefreshToken returns the refresh token currently accepted for the OAuth app.
+func (s *Server) RefreshToken() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.oauthApp == nil {
+		return ""
+	}
+
+	return s.oauthApp.RefreshToken
+}
+
+// SetAccount replaces the details of the account.
+func (s *Server) SetAccount(account hubspot.Account) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.account = account
+}
+
+// SetApp sets the private app or OAuth app reported for the access token, with the user the token was issued to.
+func (s *Server) SetApp(appId int, userId int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.appId = appId
+	s.appUserId = userId
+}
+
+// SetScopes sets the scopes reported for the access token.
+func (s *Server) SetScopes(scopes ...string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.scopes = scopes
+}
+
+// SetRateLimit limits the number of requests served within the interval, further
+// requests are rejected with 429 Too Many Requests until the interval is over.
+func (s *Server) SetRateLimit(max int, interval time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.rateLimitMax = max
+	s.rateLimitInterval = interval
+	s.rateLimitUsed = 0
+	s.rateLimitWindow = time.Time{}
+}
+
+// AddUser adds the user to the account, an ID is assigned when the user has none.
+func (s *Server) AddUser(user hubspot.User) hubspot.User {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if user.Id == "" {
+		user.Id = s.newId()
+	}
+	s.users = append(s.users, user)
+
+	return user
+}
+
+// User returns the user with the ID.
+func (s *Server) User(id string) (hubspot.User, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if i := s.userIndex(id); i >= 0 {
+		return s.users[i], true
+	}
+
+	return hubspot.User{}, false
+}
+
+// Users returns all users of the account.
+func (s *Server) Users() []hubspot.User {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return slices.Clone(s.users)
+}
+
+// WelcomeEmails returns the email addresses of the users created with a welcome email, in order.
+func (s *Server) WelcomeEmails() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return slices.Clone(s.welcomeEmails)
+}
+
+// AddTeam adds the team and its child teams, IDs are assigned to teams that have none.
+// Team members are derived from the primary and secondary teams of the users.
+func (s *Server) AddTeam(team hubspot.Team) hubspot.Team {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	team = s.assignTeamIds(team)
+	s.teams = append(s.teams, team)
+
+	return team
+}
+
+// AddRole adds the role, an ID is assigned when the role has none.
+func (s *Server) AddRole(role hubspot.Role) hubspot.Role {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if role.Id == "" {
+		role.Id = s.newId()
+	}
+	s.roles = append(s.roles, role)
+
+	return role
+}
+
+// AddBusinessUnit adds the business unit with its members, an ID is assigned when the unit has none.
+func (s *Server) AddBusinessUnit(businessUnit hubspot.BusinessUnit, userIds ...string) hubspot.BusinessUnit {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if businessUnit.Id == "" {
+		businessUnit.Id = s.newId()
+	}
+	s.businessUnits = append(s.businessUnits, businessUnit)
+	s.businessUnitUsers[businessUnit.Id] = slices.Clone(userIds)
+
+	return businessUnit
+}
+
+// BusinessUnitUsers returns the IDs of the members of the business unit.
+func (s *Server) BusinessUnitUsers(businessUnitId string) []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return slices.Clone(s.businessUnitUsers[businessUnitId])
+}
+
+// AddOwner adds the CRM owner, an ID is assigned when the owner has none.
+func (s *Server) AddOwner(owner hubspot.Owner) hubspot.Owner {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if owner.Id == "" {
+		owner.Id = s.newId()
+	}
+	s.owners = append(s.owners, owner)
+
+	return owner
+}
+
+// AddObject adds the CRM record of the object type, such as deals or contacts,
+// an ID is assigned when the record has none.
+func (s *Server) AddObject(objectType string, object hubspot.CRMObject) hubspot.CRMObject {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if object.Id == "" {
+		object.Id = s.newId()
+	}
+	object.Properties = maps.Clone(object.Properties)
+	s.objects[objectType] = append(s.objects[objectType], object)
+
+	return object
+}
+
+// Objects returns the CRM records of the object type.
+func (s *Server) Objects(objectType string) []hubspot.CRMObject {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	objects := make([]hubspot.CRMObject, 0, len(s.objects[objectType]))
+	for _, object := range s.objects[objectType] {
+		object.Properties = maps.Clone(object.Properties)
+		objects = append(objects, object)
+	}
+
+	return objects
+}
+
+// LockObjects makes the batch updates of the CRM records of the object type fail, while the other
+// records of the batch are updated, like HubSpot does for records that fail validation.
+func (s *Server) LockObjects(objectType string, ids ...string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, id := range ids {
+		s.lockedObjects[objectType+"/"+id] = true
+	}
+}
+
+// SetSearchLag makes the searches of CRM records return the records as they were before their
+// last batch update for the given number of search requests, like the asynchronous index of HubSpot.
+func (s *Server) SetSearchLag(searches int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.searchLag = searches
+}
+
+// AddSandbox links the sandbox to the account, its parent is the account when not set.
+func (s *Server) AddSandbox(sandbox hubspot.Sandbox) hubspot.Sandbox {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if sandbox.ParentId == 0 {
+		sandbox.ParentId = s.account.Id
+	}
+	s.sandboxes = append(s.sandboxes, sandbox)
+
+	return sandbox
+}
+
+// SetDeactivated changes whether the user is reported as deactivated by the CRM users search.
+func (s *Server) SetDeactivated(userId string, deactivated bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.deactivated[userId] = deactivated
+}
+
+// Deactivated reports whether the user is deactivated.
+func (s *Server) Deactivated(userId string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.deactivated[userId]
+}
+
+// AddLoginActivity records a login to the account, an ID is assigned when the login has none.
+func (s *Server) AddLoginActivity(login hubspot.LoginActivity) hubspot.LoginActivity {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if login.Id == "" {
+		login.Id = s.newId()
+	}
+	s.logins = append(s.logins, login)
+	// the login activity is served newest first
+	slices.SortStableFunc(s.logins, func(a, b hubspot.LoginActivity) int {
+		return b.LoginAt.Compare(a.LoginAt)
+	})
+
+	return login
+}
+
+// AddAuditLog records the audit log, an ID is assigned when the audit log has none.
+func (s *Server) AddAuditLog(auditLog hubspot.AuditLog) hubspot.AuditLog {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if auditLog.Id == "" {
+		auditLog.Id = s.newId()
+	}
+	s.auditLogs = append(s.auditLogs, auditLog)
+	slices.SortStableFunc(s.auditLogs, func(a, b hubspot.AuditLog) int {
+		return b.OccurredAt.Compare(a.OccurredAt)
+	})
+
+	return auditLog
+}
+
+// AddSecurityActivity records the security activity, an ID is assigned when the activity has none.
+func (s *Server) AddSecurityActivity(activity hubspot.SecurityActivity) hubspot.SecurityActivity {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if activity.Id == "" {
+		activity.Id = s.newId()
+	}
+	s.securityActivity = append(s.securityActivity, activity)
+	slices.SortStableFunc(s.securityActivity, func(a, b hubspot.SecurityActivity) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return activity
+}
+
+// InjectFailure makes the next matching requests fail with the error of the failure.
+func (s *Server) InjectFailure(failure Failure) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if failure.Times == 0 {
+		failure.Times = 1
+	}
+	s.failures = append(s.failures, &failure)
+}
+
+// Requests returns the requests received by the server, in order.
+func (s *Server) Requests() []Request {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+func (s *Server) newId() string {
+	s.nextId++
+
+	return strconv.Itoa(s.nextId)
+}
+
+func (s *Server) assignTeamIds(team hubspot.Team) hubspot.Team {
+	if team.Id == "" {
+		team.Id = s.newId()
+	}
+	children := make([]hubspot.Team, 0, len(team.ChildTeams))
+	for _, child := range team.ChildTeams {
+		children = append(children, s.assignTeamIds(child))
+	}
+	team.ChildTeams = children
+
+	return team
+}
+
+func (s *Server) userIndex(id string) int {
+	return slices.IndexFunc(s.users, func(user hubspot.User) bool {
+		return user.Id == id
+	})
+}
+
+func (s *Server) userIndexByEmail(email string) int {
+	return slices.IndexFunc(s.users, func(user hubspot.User) bool {
+		return user.Email == email
+	})
+}
+
+// handler serves the API endpoints behind authentication, injected failures and rate limiting.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /settings/v3/users", s.listUsers)
+	mux.HandleFunc("POST /settings/v3/users", s.createUser)
+	mux.HandleFunc("GET /settings/v3/users/{id}", s.getUser)
+	mux.HandleFunc("PUT /settings/v3/users/{id}", s.updateUser)
+	mux.HandleFunc("DELETE /settings/v3/users/{id}", s.deleteUser)
+	mux.HandleFunc("GET /settings/v3/users/teams", s.listTeams)
+	mux.HandleFunc("GET /settings/v3/users/roles", s.listRoles)
+	mux.HandleFunc("GET /business-units/v3/business-units/user/{userId}", s.listUserBusinessUnits)
+	mux.HandleFunc("PUT /business-units/v3/business-units/{id}/users/{userId}", s.addBusinessUnitUser)
+	mux.HandleFunc("DELETE /business-units/v3/business-units/{id}/users/{userId}", s.removeBusinessUnitUser)
+	mux.HandleFunc("GET /account-info/v3/details", s.getAccount)
+	mux.HandleFunc("GET /sandboxes/v1/sandboxes", s.listSandboxes)
+	mux.HandleFunc("GET /account-info/v3/activity/login", s.listLoginActivity)
+	mux.HandleFunc("GET /account-info/v3/activity/audit-logs", s.listAuditLogs)
+	mux.HandleFunc("GET /account-info/v3/activity/security", s.listSecurityActivity)
+	mux.HandleFunc("POST /crm/v3/objects/users/search", s.searchUsers)
+	mux.HandleFunc("PATCH /crm/v3/objects/users/{objectId}", s.updateUserObject)
+	mux.HandleFunc("POST /crm/v3/objects/{objectType}/search", s.searchObjects)
+	mux.HandleFunc("POST /crm/v3/objects/{objectType}/batch/update", s.batchUpdateObjects)
+	mux.HandleFunc("GET /crm/v3/owners", s.listOwners)
+	mux.HandleFunc("GET /crm/v3/owners/{id}", s.getOwner)
+	mux.HandleFunc("POST /oauth/v1/token", s.issueToken)
+	mux.HandleFunc("GET /oauth/v1/access-tokens/{token}", s.getAccessTokenInfo)
+	mux.HandleFunc("POST /oauth/v2/private-apps/get/access-token-info", s.getTokenInfo)
+	// fail loudly on endpoints that are not emulated, rather than with a misleading 404
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotImplemented, "NOT_IMPLEMENTED", fmt.Sprintf("hubspottest: %s %s is not emulated", r.Method, r.URL.Path))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mtx.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query()})
+		token := s.token
+		failure := s.nextFailure(r)
+		limited := s.takeRateLimit(w.Header())
+		s.mtx.Unlock()
+
+		// access tokens are obtained without being authenticated
+		authenticated := r.URL.Path == "/"+hubspot.OAuthTokenURL || r.Header.Get("Authorization") == "Bearer "+token
+		if !authenticated {
+			writeError(w, http.StatusUnauthorized, "INVALID_AUTHENTICATION", "Authentication credentials not found.")
+			return
+		}
+
+		if limited {
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITS", "You have reached your secondly limit.")
+			return
+		}
+
+		if failure != nil {
+			if failure.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(failure.RetryAfter.Seconds())))
+			}
+			writeError(w, failure.StatusCode, failure.Category, failure.Message)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// nextFailure returns the injected failure matching the request, if any.
+func (s *Server) nextFailure(r *http.Request) *Failure {
+	for i, failure := range s.failures {
+		if failure.Path != r.URL.Path || (failure.Method != "" && failure.Method != r.Method) {
+			continue
+		}
+
+		failure.Times--
+		if failure.Times == 0 {
+			s.failures = slices.Delete(s.failures, i, i+1)
+		}
+
+		return failure
+	}
+
+	return nil
+}
+
+// takeRateLimit counts the request against the rate limit and sets the rate limit headers,
+// it reports whether the request is over the limit.
+func (s *Server) takeRateLimit(header http.Header) bool {
+	if s.rateLimitMax <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(s.rateLimitWindow) >= s.rateLimitInterval {
+		s.rateLimitWindow = now
+		s.rateLimitUsed = 0
+	}
+
+	limited := s.rateLimitUsed >= s.rateLimitMax
+	if !limited {
+		s.rateLimitUsed++
+	}
+
+	header.Set("X-HubSpot-RateLimit-Max", strconv.Itoa(s.rateLimitMax))
+	header.Set("X-HubSpot-RateLimit-Remaining", strconv.Itoa(s.rateLimitMax-s.rateLimitUsed))
+	header.Set("X-HubSpot-RateLimit-Interval-Milliseconds", strconv.FormatInt(s.rateLimitInterval.Milliseconds(), 10))
+
+	return limited
+}
+
+type errorResponse struct {
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+	Category      string `json:"category,omitempty"`
+	CorrelationId string `json:"correlationId"`
+}
+
+func writeError(w http.ResponseWriter, statusCode int, category string, message string) {
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	writeJSON(w, statusCode, errorResponse{
+		Status:        "error",
+		Message:       message,
+		Category:      category,
+		CorrelationId: fmt.Sprintf("hubspottest-%d", time.Now().UnixNano()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,187 @@
+package hubspottest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-hubspot/pkg/hubspot"
+	"github.com/conductorone/baton-hubspot/pkg/hubspot/hubspottest"
+)
+
+func get(t *testing.T, s *hubspottest.Server, path string, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, s.BaseURL()+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestServerRejectsOtherTokens(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	if resp := get(t, s, hubspot.UsersBaseURL, "other"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for another token, got %d", resp.StatusCode)
+	}
+	if resp := get(t, s, hubspot.UsersBaseURL, s.Token()); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for the server token, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerRejectsEndpointsNotEmulated(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	resp := get(t, s, "crm/v3/objects/contacts/123", s.Token())
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerPagesUsers(t *testing.T) {
+	ctx := context.Background()
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	for range 5 {
+		s.AddUser(hubspot.User{})
+	}
+
+	client := s.Client()
+	var ids []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("paging did not end")
+		}
+
+		users, next, _, err := client.GetUsers(ctx, hubspot.GetUsersVars{Limit: 2, After: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, user := range users {
+			ids = append(ids, user.Id)
+		}
+		if next == "" {
+			break
+		}
+		after = next
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("expected 5 users, got %v", ids)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetRateLimit(1, time.Hour)
+
+	if resp := get(t, s, hubspot.UsersBaseURL, s.Token()); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	resp := get(t, s, hubspot.UsersBaseURL, s.Token())
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the rate limit, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("X-HubSpot-RateLimit-Remaining") != "0" {
+		t.Fatalf("expected no remaining requests, got %q", resp.Header.Get("X-HubSpot-RateLimit-Remaining"))
+	}
+}
+
+func TestServerInjectFailure(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.InjectFailure(hubspottest.Failure{
+		Path:       "/" + hubspot.UsersBaseURL,
+		StatusCode: http.StatusServiceUnavailable,
+		Times:      2,
+		RetryAfter: 3 * time.Second,
+	})
+
+	for range 2 {
+		resp := get(t, s, hubspot.UsersBaseURL, s.Token())
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") != "3" {
+			t.Fatalf("expected Retry-After 3, got %q", resp.Header.Get("Retry-After"))
+		}
+	}
+	if resp := get(t, s, hubspot.UsersBaseURL, s.Token()); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 once the failures are used, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerIssuesOAuthTokens(t *testing.T) {
+	s := hubspottest.NewServer()
+	defer s.Close()
+
+	s.SetOAuthApp(hubspottest.OAuthApp{
+		ClientId:           "client",
+		ClientSecret:       "secret",
+		RefreshToken:       "refresh",
+		RotateRefreshToken: true,
+	})
+
+	exchange := func(refreshToken string) (*http.Response, map[string]interface{}) {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"client"},
+			"client_secret": {"secret"},
+			"refresh_token": {refreshToken},
+		}
+		resp, err := s.Server.Client().Post(s.BaseURL()+hubspot.OAuthTokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		return resp, body
+	}
+
+	resp, body := exchange("refresh")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", resp.StatusCode, body)
+	}
+	if body["access_token"] != s.Token() {
+		t.Fatalf("expected the issued access token to be accepted, got %v", body["access_token"])
+	}
+	if body["refresh_token"] == "refresh" || body["refresh_token"] != s.RefreshToken() {
+		t.Fatalf("expected a rotated refresh token, got %v", body["refresh_token"])
+	}
+	if body["expires_in"] != hubspottest.DefaultAccessTokenTTL.Seconds() {
+		t.Fatalf("expected the default lifetime, got %v", body["expires_in"])
+	}
+
+	if resp, _ := exchange("refresh"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the rotated refresh token to be rejected, got %d", resp.StatusCode)
+	}
+
+	if resp := get(t, s, "oauth/v1/access-tokens/"+s.Token(), s.Token()); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the access token info, got %d", resp.StatusCode)
+	}
+}
@@ -1,5 +1,7 @@
 package hubspot
 
+import "time"
+
 type BaseResource struct {
 	Id string `json:"id"`
 }
@@ -7,6 +9,8 @@ type BaseResource struct {
 type User struct {
 	BaseResource
 	Email            string   `json:"email"`
+	FirstName        string   `json:"firstName,omitempty"`
+	LastName         string   `json:"lastName,omitempty"`
 	RoleIDs          []string `json:"roleIds"`
 	TeamId           string   `json:"primaryTeamId"`
 	SecondaryTeamIDs []string `json:"secondaryTeamIds"`
@@ -18,6 +22,7 @@ type Team struct {
 	Name             string   `json:"name"`
 	UserIDs          []string `json:"userIds"`
 	SecondaryUserIDs []string `json:"secondaryUserIds"`
+	ChildTeams       []Team   `json:"childTeams,omitempty"`
 }
 
 type UserObject struct {
@@ -30,11 +35,32 @@ type UserObjectProperties struct {
 	Deactivated string `json:"hs_deactivated,omitempty"`
 }
 
+// Account types of the account details, sandboxes and test accounts have no sandboxes of their own.
+const (
+	AccountTypeStandard      = "STANDARD"
+	AccountTypeSandbox       = "SANDBOX"
+	AccountTypeDeveloperTest = "DEVELOPER_TEST"
+)
+
 type Account struct {
 	Id   int    `json:"portalId"`
 	Type string `json:"accountType"`
 }
 
+// IsProduction reports whether the account is a production account, the only kind sandboxes are linked to.
+func (a *Account) IsProduction() bool {
+	return a.Type != AccountTypeSandbox && a.Type != AccountTypeDeveloperTest
+}
+
+// Sandbox is a standard or development sandbox account linked to a production account.
+type Sandbox struct {
+	Id        int       `json:"sandboxHubId"`
+	ParentId  int       `json:"parentHubId"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type Role struct {
 	BaseResource
 	Name string `json:"name"`
@@ -49,6 +75,43 @@ func NewRole(id, name string) *Role {
 	}
 }
 
+type BusinessUnit struct {
+	BaseResource
+	Name string `json:"name"`
+}
+
+type Owner struct {
+	BaseResource
+	Email     string    `json:"email"`
+	FirstName string    `json:"firstName,omitempty"`
+	LastName  string    `json:"lastName,omitempty"`
+	UserId    int       `json:"userId,omitempty"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CRMObject is a record of any CRM object type, such as a deal, contact, company or ticket.
+type CRMObject struct {
+	BaseResource
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type ActingUser struct {
+	UserId    string `json:"userId,omitempty"`
+	UserEmail string `json:"userEmail,omitempty"`
+}
+
+type AuditLog struct {
+	Id             string     `json:"id"`
+	Category       string     `json:"category"`
+	SubCategory    string     `json:"subCategory,omitempty"`
+	Action         string     `json:"action"`
+	TargetObjectId string     `json:"targetObjectId,omitempty"`
+	OccurredAt     time.Time  `json:"occurredAt"`
+	ActingUser     ActingUser `json:"actingUser,omitempty"`
+}
+
 type Page struct {
 	After string `json:"after,omitempty"`
 	Link  string `json:"link,omitempty"`
@@ -0,0 +1,105 @@
+package hubspot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tokenExpiryLeeway refreshes the access token shortly before it expires.
+const tokenExpiryLeeway = time.Minute
+
+// OAuthCredentials are the credentials of a HubSpot OAuth app installed in the account.
+type OAuthCredentials struct {
+	ClientId     string
+	ClientSecret string
+	RefreshToken string
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// NewOAuthClient returns a client authenticated with an OAuth app, access tokens
+// are obtained from the refresh token and refreshed before they expire.
+func NewOAuthClient(credentials OAuthCredentials, httpClient *http.Client, opts ...ClientOption) *Client {
+	c := &Client{
+		httpClient: httpClient,
+		oauth:      &credentials,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// token returns the access token used for requests, refreshing the OAuth access token when needed.
+// When staleToken is set, the token is refreshed if it was not rotated since it was rejected.
+func (c *Client) token(ctx context.Context, staleToken string) (string, error) {
+	if c.oauth == nil {
+		return c.accessToken, nil
+	}
+
+	c.tokenMtx.Lock()
+	defer c.tokenMtx.Unlock()
+
+	expired := time.Now().Add(tokenExpiryLeeway).After(c.tokenExpiresAt)
+	if c.accessToken != "" && !expired && (staleToken == "" || staleToken != c.accessToken) {
+		return c.accessToken, nil
+	}
+
+	if err := c.refreshAccessToken(ctx); err != nil {
+		return "", err
+	}
+
+	return c.accessToken, nil
+}
+
+// refreshAccessToken exchanges the refresh token for a new access token,
+// keeping the rotated refresh token for the following exchanges.
+func (c *Client) refreshAccessToken(ctx context.Context) error {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", c.oauth.ClientId)
+	form.Set("client_secret", c.oauth.ClientSecret)
+	form.Set("refresh_token", c.oauth.RefreshToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(OAuthTokenURL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rawResponse, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rawResponse.Body.Close()
+
+	if rawResponse.StatusCode >= 300 {
+		return fmt.Errorf("hubspot: failed to refresh access token: %w", newAPIError(rawResponse))
+	}
+
+	var res tokenResponse
+	if err := json.NewDecoder(rawResponse.Body).Decode(&res); err != nil {
+		return err
+	}
+
+	c.accessToken = res.AccessToken
+	c.tokenExpiresAt = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	if res.RefreshToken != "" {
+		c.oauth.RefreshToken = res.RefreshToken
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package hubspot
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+	defaultRetryBudget    = 2 * time.Minute
+	defaultMaxRetries     = 5
+)
+
+// RetryOptions configures the retrying transport, see DefaultRetryOptions.
+type RetryOptions struct {
+	// MaxRetries is the maximum number of retries of a single request, requests are not retried when zero.
+	MaxRetries int
+	// Budget is the maximum total time spent waiting between retries of a single request,
+	// requests are not retried when zero.
+	Budget time.Duration
+	// BaseDelay is the initial delay of the exponential backoff.
+	BaseDelay time.Duration
+	// MaxDelay caps a single delay between retries.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryOptions returns the options retrying a request up to 5 times within 2 minutes.
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		MaxRetries: defaultMaxRetries,
+		Budget:     defaultRetryBudget,
+		BaseDelay:  defaultRetryBaseDelay,
+		MaxDelay:   defaultRetryMaxDelay,
+	}
+}
+
+type retryTransport struct {
+	next    http.RoundTripper
+	options RetryOptions
+}
+
+// NewRetryTransport wraps the transport with retries of rate limited (429) and
+// transient (502, 503, 504) responses. Transient failures are retried only for
+// idempotent methods, a POST may have been applied before the failure. The delay
+// honours the Retry-After and X-HubSpot-RateLimit-Interval-Milliseconds headers
+// and falls back to a jittered exponential backoff.
+func NewRetryTransport(next http.RoundTripper, options RetryOptions) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if options.BaseDelay == 0 {
+		options.BaseDelay = defaultRetryBaseDelay
+	}
+	if options.MaxDelay == 0 {
+		options.MaxDelay = defaultRetryMaxDelay
+	}
+
+	return &retryTransport{
+		next:    next,
+		options: options,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	l := ctxzap.Extract(ctx)
+
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		// a transport must not modify the request, retries are sent on a copy with a new body
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		response, err := t.next.RoundTrip(attemptReq)
+		if err != nil || !isRetryable(req.Method, response.StatusCode) {
+			return response, err
+		}
+
+		if attempt >= t.options.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return response, nil
+		}
+
+		delay := t.retryDelay(response, attempt)
+		if waited+delay > t.options.Budget {
+			return response, nil
+		}
+
+		l.Debug(
+			"hubspot-connector: retrying request",
+			zap.String("url", req.URL.String()),
+			zap.Int("status_code", response.StatusCode),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+		)
+
+		response.Body.Close()
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		waited += delay
+	}
+}
+
+// retryDelay returns how long to wait before the next attempt.
+func (t *retryTransport) retryDelay(response *http.Response, attempt int) time.Duration {
+	if delay, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+		return min(delay, t.options.MaxDelay)
+	}
+
+	// the rate limit window resets after the interval once the remaining requests are used up
+	if response.StatusCode == http.StatusTooManyRequests && response.Header.Get("X-HubSpot-RateLimit-Remaining") == "0" {
+		intervalMs, err := strconv.ParseInt(response.Header.Get("X-HubSpot-RateLimit-Interval-Milliseconds"), 10, 64)
+		if err == nil && intervalMs > 0 {
+			return min(time.Duration(intervalMs)*time.Millisecond, t.options.MaxDelay)
+		}
+	}
+
+	// full jitter exponential backoff
+	backoff := min(t.options.BaseDelay<<attempt, t.options.MaxDelay)
+	return time.Duration(rand.Int64N(int64(backoff)) + 1) //nolint:gosec // jitter does not need a secure random source
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, seconds >= 0
+	}
+
+	if retryAt, err := http.ParseTime(value); err == nil {
+		return max(time.Until(retryAt), 0), true
+	}
+
+	return 0, false
+}
+
+// isRetryable reports whether the response is worth retrying. Rate limited requests were
+// rejected before being processed, other failures are retried only when repeating the request is safe.
+func isRetryable(method string, statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	default:
+		return false
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,220 @@
+package hubspot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingServer serves the status codes in order, then 200, and records the bodies of the requests.
+type failingServer struct {
+	*httptest.Server
+
+	mtx         sync.Mutex
+	statusCodes []int
+	header      http.Header
+	bodies      []string
+}
+
+func newFailingServer(t *testing.T, header http.Header, statusCodes ...int) *failingServer {
+	t.Helper()
+
+	s := &failingServer{statusCodes: statusCodes, header: header}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+
+		s.bodies = append(s.bodies, string(body))
+		if len(s.statusCodes) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		for name, values := range s.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(s.statusCodes[0])
+		s.statusCodes = s.statusCodes[1:]
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *failingServer) attempts() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.bodies
+}
+
+func testRetryOptions() RetryOptions {
+	return RetryOptions{
+		MaxRetries: 3,
+		Budget:     time.Second,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   10 * time.Millisecond,
+	}
+}
+
+func send(t *testing.T, transport http.RoundTripper, method string, url string, body string) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	return response
+}
+
+func TestRetryTransportRetriesIdempotentRequests(t *testing.T) {
+	s := newFailingServer(t, nil, http.StatusServiceUnavailable, http.StatusBadGateway)
+	transport := NewRetryTransport(nil, testRetryOptions())
+
+	response := send(t, transport, http.MethodPut, s.URL, `{"roleId":"1"}`)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after the retries, got %d", response.StatusCode)
+	}
+
+	// the body is replayed on every attempt
+	attempts := s.attempts()
+	if len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(attempts))
+	}
+	for _, body := range attempts {
+		if body != `{"roleId":"1"}` {
+			t.Fatalf("expected the body to be replayed, got %q", body)
+		}
+	}
+}
+
+func TestRetryTransportDoesNotModifyTheRequest(t *testing.T) {
+	s := newFailingServer(t, nil, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	transport := NewRetryTransport(nil, testRetryOptions())
+
+	req, err := http.NewRequest(http.MethodPut, s.URL, strings.NewReader(`{"roleId":"1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+
+	response, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || len(s.attempts()) != 3 {
+		t.Fatalf("expected 200 after 3 attempts, got %d after %d", response.StatusCode, len(s.attempts()))
+	}
+	if req.Body != body {
+		t.Fatal("expected the body of the request to be kept")
+	}
+}
+
+func TestRetryTransportRetriesPostOnlyWhenRateLimited(t *testing.T) {
+	s := newFailingServer(t, nil, http.StatusServiceUnavailable)
+	transport := NewRetryTransport(nil, testRetryOptions())
+
+	response := send(t, transport, http.MethodPost, s.URL, `{"email":"jane@example.com"}`)
+	if response.StatusCode != http.StatusServiceUnavailable || len(s.attempts()) != 1 {
+		t.Fatalf("expected a failed POST not to be retried, got %d after %d attempts", response.StatusCode, len(s.attempts()))
+	}
+
+	s = newFailingServer(t, nil, http.StatusTooManyRequests)
+	response = send(t, transport, http.MethodPost, s.URL, `{"email":"jane@example.com"}`)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected a rate limited POST to be retried, got %d", response.StatusCode)
+	}
+	if attempts := s.attempts(); len(attempts) != 2 || attempts[1] != `{"email":"jane@example.com"}` {
+		t.Fatalf("expected the body to be replayed, got %q", attempts)
+	}
+}
+
+func TestRetryTransportHonoursRetryAfter(t *testing.T) {
+	s := newFailingServer(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests)
+	options := testRetryOptions()
+	options.Budget = 2 * time.Second
+	options.MaxDelay = 2 * time.Second
+	transport := NewRetryTransport(nil, options)
+
+	start := time.Now()
+	response := send(t, transport, http.MethodGet, s.URL, "")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after the retry, got %d", response.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected the retry to wait for Retry-After, waited %s", elapsed)
+	}
+}
+
+func TestRetryTransportBudget(t *testing.T) {
+	// the delay requested by the server exceeds the budget, the response is returned without waiting
+	s := newFailingServer(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+	options := testRetryOptions()
+	options.MaxDelay = time.Minute
+	transport := NewRetryTransport(nil, options)
+
+	start := time.Now()
+	response := send(t, transport, http.MethodGet, s.URL, "")
+	if response.StatusCode != http.StatusTooManyRequests || len(s.attempts()) != 1 {
+		t.Fatalf("expected the rate limited response, got %d after %d attempts", response.StatusCode, len(s.attempts()))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected no wait beyond the budget, waited %s", elapsed)
+	}
+
+	// a zero budget disables retries
+	s = newFailingServer(t, nil, http.StatusServiceUnavailable)
+	options = testRetryOptions()
+	options.Budget = 0
+	transport = NewRetryTransport(nil, options)
+
+	response = send(t, transport, http.MethodGet, s.URL, "")
+	if response.StatusCode != http.StatusServiceUnavailable || len(s.attempts()) != 1 {
+		t.Fatalf("expected no retry with a zero budget, got %d after %d attempts", response.StatusCode, len(s.attempts()))
+	}
+}
+
+func TestRetryTransportMaxRetries(t *testing.T) {
+	s := newFailingServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	transport := NewRetryTransport(nil, testRetryOptions())
+
+	response := send(t, transport, http.MethodGet, s.URL, "")
+	if response.StatusCode != http.StatusBadGateway || len(s.attempts()) != 4 {
+		t.Fatalf("expected to give up after 3 retries, got %d after %d attempts", response.StatusCode, len(s.attempts()))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay, ok := parseRetryAfter("3"); !ok || delay != 3*time.Second {
+		t.Fatalf("expected 3s, got %s %v", delay, ok)
+	}
+	if _, ok := parseRetryAfter("-1"); ok {
+		t.Fatal("expected a negative delay to be ignored")
+	}
+	if delay, ok := parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)); !ok || delay != 0 {
+		t.Fatalf("expected a date in the past not to wait, got %s %v", delay, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Fatal("expected an invalid value to be ignored")
+	}
+}